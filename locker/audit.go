@@ -0,0 +1,229 @@
+package locker
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/lockerpm/secrets-sdk-go/types"
+)
+
+// AuditRecord describes one create, update or delete made by the client. Keys are only recorded as the hash the
+// server knows them by, values are never recorded.
+type AuditRecord struct {
+	Sequence    uint64            `json:"seq"`
+	Timestamp   time.Time         `json:"timestamp"`
+	Operation   string            `json:"operation"`
+	Kind        string            `json:"kind"`
+	ItemID      string            `json:"item_id,omitempty"`
+	KeyHash     string            `json:"key_hash,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+	Result      string            `json:"result"`
+	Error       string            `json:"error,omitempty"`
+	Actor       map[string]string `json:"actor,omitempty"`
+	// PrevHash and Hash chain the records, they are set by the sink
+	PrevHash string `json:"prev_hash"`
+	Hash     string `json:"hash"`
+}
+
+// AuditSink receives the audit records of a client, see SetAuditSink
+type AuditSink interface {
+	Record(record AuditRecord) error
+}
+
+// SetAuditSink enables auditing of CreateSecret, UpdateSecret, DeleteSecret and the environment mutations,
+// actor is copied into every record (e.g. {"user": "ci", "pipeline": "1234"}). A nil sink disables auditing.
+// Calls skipped in dry-run mode are not recorded.
+func (locker *Locker) SetAuditSink(sink AuditSink, actor map[string]string) {
+	locker.auditSink = sink
+	locker.auditActor = make(map[string]string, len(actor))
+	for name, value := range actor {
+		locker.auditActor[name] = value
+	}
+}
+
+// audit records the outcome of a mutation, failing to record is reported through err
+func (locker *Locker) audit(operation, kind, ID, key string, env *string, err error) error {
+	if locker.auditSink == nil || locker.DryRun {
+		return err
+	}
+
+	record := AuditRecord{
+		Timestamp:   time.Now().UTC(),
+		Operation:   operation,
+		Kind:        kind,
+		ItemID:      ID,
+		Environment: derefString(env),
+		Result:      types.AUDIT_RESULT_SUCCESS,
+		Actor:       locker.auditActor,
+	}
+	if key != "" {
+		// without a profile (e.g. invalid credentials) the key cannot be hashed, the record is kept anyway
+		record.KeyHash, _ = locker.getHash(key)
+	}
+	if err != nil {
+		record.Result = types.AUDIT_RESULT_FAILURE
+		record.Error = locker.Redactor().Redact(err.Error())
+	}
+
+	auditErr := locker.auditSink.Record(record)
+	if auditErr != nil {
+		auditErr = fmt.Errorf("error recording audit record: %w", auditErr)
+		if err != nil {
+			return fmt.Errorf("%w (%v)", err, auditErr)
+		}
+		return auditErr
+	}
+
+	return err
+}
+
+// JSONLAuditSink appends hash-chained records to a JSON Lines file, one record per line
+type JSONLAuditSink struct {
+	mutex    sync.Mutex
+	file     *os.File
+	sequence uint64
+	lastHash string
+}
+
+// NewJSONLAuditSink opens (or creates, with mode 0600) the journal at path and verifies the existing records
+// before appending to them
+func NewJSONLAuditSink(path string) (*JSONLAuditSink, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		types.CURRENT_ERR = types.ERR_FILE
+		return nil, fmt.Errorf("error opening audit journal: %w", err)
+	}
+
+	last, err := VerifyAuditLog(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &JSONLAuditSink{file: file, sequence: last.Sequence, lastHash: last.Hash}, nil
+}
+
+func (sink *JSONLAuditSink) Record(record AuditRecord) error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+
+	record.Sequence = sink.sequence + 1
+	record.PrevHash = sink.lastHash
+	line, err := sealAuditRecord(&record)
+	if err != nil {
+		return err
+	}
+
+	_, err = sink.file.Write(append(line, '\n'))
+	if err != nil {
+		types.CURRENT_ERR = types.ERR_FILE
+		return fmt.Errorf("error writing audit journal: %w", err)
+	}
+	err = sink.file.Sync()
+	if err != nil {
+		types.CURRENT_ERR = types.ERR_FILE
+		return fmt.Errorf("error writing audit journal: %w", err)
+	}
+
+	sink.sequence = record.Sequence
+	sink.lastHash = record.Hash
+	return nil
+}
+
+func (sink *JSONLAuditSink) Close() error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	return sink.file.Close()
+}
+
+// AuditChainError points at the first record of a journal that breaks the chain
+type AuditChainError struct {
+	Line   int
+	Reason string
+}
+
+func (chainErr *AuditChainError) Error() string {
+	return fmt.Sprintf("audit journal line %d: %s", chainErr.Line, chainErr.Reason)
+}
+
+// VerifyAuditLog checks that every record of the journal read from r is intact and chained to the previous one,
+// and returns the last record. Modified, removed, reordered or inserted records fail with an *AuditChainError;
+// truncating the end of the journal cannot be detected from the journal alone, compare the last hash with a copy
+// kept elsewhere for that.
+func VerifyAuditLog(r io.Reader) (AuditRecord, error) {
+	var last AuditRecord
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		raw := scanner.Bytes()
+
+		var record AuditRecord
+		err := json.Unmarshal(raw, &record)
+		if err != nil {
+			types.CURRENT_ERR = types.ERR_DATA
+			return AuditRecord{}, &AuditChainError{Line: line, Reason: "invalid record"}
+		}
+
+		if record.Sequence != last.Sequence+1 {
+			types.CURRENT_ERR = types.ERR_DATA
+			return AuditRecord{}, &AuditChainError{Line: line, Reason: fmt.Sprintf("expected sequence %d, got %d", last.Sequence+1, record.Sequence)}
+		}
+		if record.PrevHash != last.Hash {
+			types.CURRENT_ERR = types.ERR_DATA
+			return AuditRecord{}, &AuditChainError{Line: line, Reason: "previous hash does not match"}
+		}
+
+		sealed := record
+		expected, err := sealAuditRecord(&sealed)
+		if err != nil {
+			return AuditRecord{}, err
+		}
+		if sealed.Hash != record.Hash || !bytes.Equal(expected, raw) {
+			types.CURRENT_ERR = types.ERR_DATA
+			return AuditRecord{}, &AuditChainError{Line: line, Reason: "record was modified"}
+		}
+
+		last = record
+	}
+	if err := scanner.Err(); err != nil {
+		types.CURRENT_ERR = types.ERR_FILE
+		return AuditRecord{}, fmt.Errorf("error reading audit journal: %w", err)
+	}
+
+	return last, nil
+}
+
+// sealAuditRecord sets record.Hash to the SHA-256 of the previous hash and the record encoded without its hash,
+// and returns the encoded line
+func sealAuditRecord(record *AuditRecord) ([]byte, error) {
+	record.Hash = ""
+	payload, err := json.Marshal(record)
+	if err != nil {
+		types.CURRENT_ERR = types.ERR_FUNC
+		return nil, fmt.Errorf("error marshalling audit record: %w", err)
+	}
+
+	hasher := sha256.New()
+	hasher.Write([]byte(record.PrevHash))
+	hasher.Write([]byte{'\n'})
+	hasher.Write(payload)
+	record.Hash = hex.EncodeToString(hasher.Sum(nil))
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		types.CURRENT_ERR = types.ERR_FUNC
+		return nil, fmt.Errorf("error marshalling audit record: %w", err)
+	}
+	return line, nil
+}
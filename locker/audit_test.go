@@ -0,0 +1,279 @@
+package locker
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lockerpm/secrets-sdk-go/types"
+)
+
+// writeTestJournal records count records in a new journal and returns its path and lines
+func writeTestJournal(t *testing.T, count int) (string, [][]byte) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	sink, err := NewJSONLAuditSink(path)
+	if err != nil {
+		t.Fatalf("audit sink broke, error: %v", err)
+	}
+	for i := 0; i < count; i++ {
+		err = sink.Record(AuditRecord{Operation: types.OPERATION_UPDATE, Kind: types.FETCH_KIND_SEC, ItemID: "sec-1", Result: types.AUDIT_RESULT_SUCCESS})
+		if err != nil {
+			t.Fatalf("audit sink broke, error: %v", err)
+		}
+	}
+	err = sink.Close()
+	if err != nil {
+		t.Fatalf("audit sink broke, error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading audit journal broke, error: %v", err)
+	}
+	return path, bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n"))
+}
+
+func mustUnmarshal(t *testing.T, data []byte, v interface{}) {
+	t.Helper()
+
+	err := json.Unmarshal(data, v)
+	if err != nil {
+		t.Fatalf("decoding %s broke, error: %v", data, err)
+	}
+}
+
+func joinLines(lines ...[]byte) []byte {
+	return append(bytes.Join(lines, []byte("\n")), '\n')
+}
+
+func TestVerifyAuditLog(t *testing.T) {
+	_, lines := writeTestJournal(t, 4)
+
+	last, err := VerifyAuditLog(bytes.NewReader(joinLines(lines...)))
+	if err != nil {
+		t.Fatalf("verify audit log broke, error: %v", err)
+	}
+	if last.Sequence != 4 || last.Hash == "" {
+		t.Fatalf("verify audit log broke, expecting the 4th record, getting %+v", last)
+	}
+
+	last, err = VerifyAuditLog(bytes.NewReader(nil))
+	if err != nil || last.Sequence != 0 {
+		t.Fatalf("verify audit log broke for an empty journal, getting %+v (%v)", last, err)
+	}
+}
+
+func TestVerifyAuditLogTampering(t *testing.T) {
+	_, lines := writeTestJournal(t, 4)
+
+	// a record edited and sealed again with its own previous hash
+	forged := AuditRecord{}
+	mustUnmarshal(t, lines[1], &forged)
+	forged.ItemID = "sec-2"
+	resealed, err := sealAuditRecord(&forged)
+	if err != nil {
+		t.Fatalf("seal audit record broke, error: %v", err)
+	}
+
+	// a record appended to the chain, then moved before the last one
+	appended := AuditRecord{}
+	mustUnmarshal(t, lines[3], &appended)
+	appended.Sequence, appended.PrevHash = 5, appended.Hash
+	inserted, err := sealAuditRecord(&appended)
+	if err != nil {
+		t.Fatalf("seal audit record broke, error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		journal []byte
+		line    int
+		reason  string
+	}{
+		{
+			name:    "edited",
+			journal: joinLines(lines[0], bytes.Replace(lines[1], []byte(`"sec-1"`), []byte(`"sec-2"`), 1), lines[2], lines[3]),
+			line:    2,
+			reason:  "record was modified",
+		},
+		{
+			name:    "edited hash",
+			journal: joinLines(lines[0], bytes.Replace(lines[1], []byte(`"hash":"`), []byte(`"hash":"0`), 1), lines[2], lines[3]),
+			line:    2,
+			reason:  "record was modified",
+		},
+		{
+			name:    "edited and resealed",
+			journal: joinLines(lines[0], resealed, lines[2], lines[3]),
+			line:    3,
+			reason:  "previous hash does not match",
+		},
+		{
+			name:    "reordered",
+			journal: joinLines(lines[0], lines[2], lines[1], lines[3]),
+			line:    2,
+			reason:  "expected sequence 2, got 3",
+		},
+		{
+			name:    "deleted",
+			journal: joinLines(lines[0], lines[2], lines[3]),
+			line:    2,
+			reason:  "expected sequence 2, got 3",
+		},
+		{
+			name:    "deleted first",
+			journal: joinLines(lines[1], lines[2], lines[3]),
+			line:    1,
+			reason:  "expected sequence 1, got 2",
+		},
+		{
+			name:    "duplicated",
+			journal: joinLines(lines[0], lines[1], lines[1], lines[2], lines[3]),
+			line:    3,
+			reason:  "expected sequence 3, got 2",
+		},
+		{
+			name:    "inserted",
+			journal: joinLines(lines[0], lines[1], lines[2], inserted, lines[3]),
+			line:    4,
+			reason:  "expected sequence 4, got 5",
+		},
+		{
+			name:    "invalid",
+			journal: joinLines(lines[0], []byte("{"), lines[1]),
+			line:    2,
+			reason:  "invalid record",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := VerifyAuditLog(bytes.NewReader(test.journal))
+			var chainErr *AuditChainError
+			if !errors.As(err, &chainErr) {
+				t.Fatalf("verify audit log broke, expecting a chain error, getting %v", err)
+			}
+			if chainErr.Line != test.line || chainErr.Reason != test.reason {
+				t.Fatalf("verify audit log broke, expecting line %d (%s), getting line %d (%s) instead", test.line, test.reason, chainErr.Line, chainErr.Reason)
+			}
+		})
+	}
+}
+
+func TestJSONLAuditSinkReopen(t *testing.T) {
+	path, lines := writeTestJournal(t, 2)
+
+	sink, err := NewJSONLAuditSink(path)
+	if err != nil {
+		t.Fatalf("reopening audit sink broke, error: %v", err)
+	}
+	err = sink.Record(AuditRecord{Operation: types.OPERATION_DELETE, Kind: types.FETCH_KIND_SEC, Result: types.AUDIT_RESULT_SUCCESS})
+	if err != nil {
+		t.Fatalf("audit sink broke, error: %v", err)
+	}
+	sink.Close()
+
+	journal, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("opening audit journal broke, error: %v", err)
+	}
+	defer journal.Close()
+
+	last, err := VerifyAuditLog(journal)
+	if err != nil {
+		t.Fatalf("verify audit log broke after reopening, error: %v", err)
+	}
+	var second AuditRecord
+	mustUnmarshal(t, lines[1], &second)
+	if last.Sequence != 3 || last.PrevHash != second.Hash || last.Operation != types.OPERATION_DELETE {
+		t.Fatalf("audit sink broke, the chain must continue after reopening, getting %+v", last)
+	}
+}
+
+func TestJSONLAuditSinkRejectsTamperedJournal(t *testing.T) {
+	path, lines := writeTestJournal(t, 3)
+
+	err := os.WriteFile(path, joinLines(lines[0], lines[2]), 0600)
+	if err != nil {
+		t.Fatalf("writing audit journal broke, error: %v", err)
+	}
+
+	_, err = NewJSONLAuditSink(path)
+	var chainErr *AuditChainError
+	if !errors.As(err, &chainErr) {
+		t.Fatalf("audit sink broke, a tampered journal must not be appended to, getting %v", err)
+	}
+}
+
+func TestClientAudit(t *testing.T) {
+	server, client := newTestServer(t)
+	server.addEnvironment(t, "production")
+	server.addSecret(t, "SHARED", "shared-value", "")
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	sink, err := NewJSONLAuditSink(path)
+	if err != nil {
+		t.Fatalf("audit sink broke, error: %v", err)
+	}
+	defer sink.Close()
+	client.SetAuditSink(sink, map[string]string{"user": "ci"})
+
+	const value = "plain-s3cr3t-value"
+	prod := "production"
+	created, err := client.CreateSecret(&InputSecData{Key: stringPointer("API_TOKEN"), Value: stringPointer(value), Env: copyString(&prod)})
+	if err != nil {
+		t.Fatalf("create secret broke, error: %v", err)
+	}
+
+	// SHARED only exists in ALL, deleting it from production fails and is recorded
+	err = client.DeleteSecret("SHARED", &prod)
+	if err == nil {
+		t.Fatalf("delete secret broke, expecting an error")
+	}
+
+	err = client.DeleteSecret("API_TOKEN", &prod)
+	if err != nil {
+		t.Fatalf("delete secret broke, error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading audit journal broke, error: %v", err)
+	}
+	if strings.Contains(string(data), value) || strings.Contains(string(data), "API_TOKEN") {
+		t.Fatalf("audit broke, the journal must not contain keys or values: %s", data)
+	}
+
+	var records []AuditRecord
+	for _, line := range bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n")) {
+		var record AuditRecord
+		mustUnmarshal(t, line, &record)
+		records = append(records, record)
+	}
+
+	want := []struct {
+		operation, result, ID, keyHash string
+	}{
+		{types.OPERATION_CREATE, types.AUDIT_RESULT_SUCCESS, created.ID, server.hash("API_TOKEN")},
+		{types.OPERATION_DELETE, types.AUDIT_RESULT_FAILURE, "", server.hash("SHARED")},
+		{types.OPERATION_DELETE, types.AUDIT_RESULT_SUCCESS, created.ID, server.hash("API_TOKEN")},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("audit broke, expecting %d records, getting %d instead", len(want), len(records))
+	}
+	for i, record := range records {
+		if record.Operation != want[i].operation || record.Result != want[i].result || record.ItemID != want[i].ID ||
+			record.KeyHash != want[i].keyHash || record.Environment != prod || record.Actor["user"] != "ci" {
+			t.Fatalf("audit broke, record %d is %+v", i, record)
+		}
+	}
+	if records[1].Error != "no secret found with provided name and env" {
+		t.Fatalf("audit broke, expecting the error of the failed delete, getting %q", records[1].Error)
+	}
+}
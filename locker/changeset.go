@@ -0,0 +1,319 @@
+package locker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/lockerpm/secrets-sdk-go/types"
+)
+
+// Changeset groups secret changes applied in order by Apply, a failure reverts the changes already applied
+type Changeset struct {
+	locker  *Locker
+	changes []plannedChange
+}
+
+type plannedChange struct {
+	action string
+	key    string
+	env    *string
+	// new state, nil fields are left unchanged by updates
+	newKey   *string
+	newValue *string
+	newDesc  *string
+	newEnv   *string
+}
+
+// ChangeResult reports what happened to one change of a Changeset
+type ChangeResult struct {
+	Action      string `json:"action"`
+	Key         string `json:"key"`
+	Environment string `json:"environment,omitempty"`
+	Applied     bool   `json:"applied"`
+	RolledBack  bool   `json:"rolled_back"`
+	Error       error  `json:"-"`
+	// RollbackError is set when the change was applied but could not be reverted
+	RollbackError error `json:"-"`
+	// RestoredID is the ID of the secret recreated to roll back a deletion: the original ID and revision date
+	// are lost, the restored secret only keeps the key, value, description and environment
+	RestoredID string `json:"restored_id,omitempty"`
+}
+
+type ChangesetResult struct {
+	Changes []ChangeResult
+	// Failed is the index of the change that failed, -1 when every change was applied
+	Failed int
+}
+
+// NewChangeset starts an empty changeset
+func (locker *Locker) NewChangeset() *Changeset {
+	return &Changeset{locker: locker}
+}
+
+// Create adds the creation of input.Key in input.Env (ALL when nil)
+func (changeset *Changeset) Create(input InputSecData) *Changeset {
+	changeset.changes = append(changeset.changes, plannedChange{
+		action:   types.ACTION_CREATE,
+		key:      derefString(input.Key),
+		env:      copyString(input.Env),
+		newValue: copyString(input.Value),
+		newDesc:  copyString(input.Desc),
+	})
+	return changeset
+}
+
+// Update adds an update of key in env, with the same semantics as UpdateSecret
+func (changeset *Changeset) Update(key string, env *string, input InputSecData) *Changeset {
+	changeset.changes = append(changeset.changes, plannedChange{
+		action:   types.ACTION_UPDATE,
+		key:      key,
+		env:      copyString(env),
+		newKey:   copyString(input.Key),
+		newValue: copyString(input.Value),
+		newDesc:  copyString(input.Desc),
+		newEnv:   copyString(input.Env),
+	})
+	return changeset
+}
+
+// Delete adds the deletion of key from env (ALL when nil)
+func (changeset *Changeset) Delete(key string, env *string) *Changeset {
+	changeset.changes = append(changeset.changes, plannedChange{
+		action: types.ACTION_DELETE,
+		key:    key,
+		env:    copyString(env),
+	})
+	return changeset
+}
+
+// Apply captures the current state of the updated and deleted secrets, then runs the changes in order. When a change
+// fails (or ctx is done), the changes already applied are reverted in reverse order, best-effort: the result reports
+// which ones were rolled back and which ones could not be. The failed change is checked on the server first, and
+// reverted as well when it took effect anyway (it is then reported as Applied, with its Error). Deletions are reverted
+// by creating the secret again, under a new ID reported in RestoredID. Updates and deletions must target secrets that
+// exist before Apply, nothing is applied otherwise.
+func (changeset *Changeset) Apply(ctx context.Context) (ChangesetResult, error) {
+	result := ChangesetResult{Changes: make([]ChangeResult, len(changeset.changes)), Failed: -1}
+	for i, change := range changeset.changes {
+		result.Changes[i] = ChangeResult{Action: change.action, Key: change.key, Environment: derefString(change.env)}
+	}
+
+	for i, change := range changeset.changes {
+		if change.action == types.ACTION_CREATE && (change.key == "" || change.newValue == nil) {
+			types.CURRENT_ERR = types.ERR_INPUT
+			result.Failed = i
+			result.Changes[i].Error = fmt.Errorf("secret's name and value must not be empty")
+			return result, fmt.Errorf("change %d (%s %s): %w", i, change.action, change.key, result.Changes[i].Error)
+		}
+	}
+
+	previous := make([]types.Secret, len(changeset.changes))
+	for i, change := range changeset.changes {
+		if change.action == types.ACTION_CREATE {
+			continue
+		}
+
+		secObj, err := changeset.locker.GetSecret(change.key, change.env)
+		if err != nil {
+			result.Failed = i
+			result.Changes[i].Error = err
+			return result, fmt.Errorf("error reading %s before applying changes: %w", change.key, err)
+		}
+		previous[i] = secObj
+	}
+
+	for i, change := range changeset.changes {
+		attempted := false
+		err := ctx.Err()
+		if err == nil {
+			attempted = true
+			err = changeset.locker.applyChange(change)
+		}
+		if err != nil {
+			result.Failed = i
+			result.Changes[i].Error = err
+			rollbackErr := changeset.rollback(&result, previous, i, attempted)
+			return result, errors.Join(fmt.Errorf("change %d (%s %s): %w", i, change.action, change.key, err), rollbackErr)
+		}
+		result.Changes[i].Applied = true
+	}
+
+	return result, nil
+}
+
+func (locker *Locker) applyChange(change plannedChange) error {
+	var err error
+	switch change.action {
+	case types.ACTION_CREATE:
+		key := change.key
+		_, err = locker.CreateSecret(&InputSecData{
+			Key:   &key,
+			Value: copyString(change.newValue),
+			Desc:  copyString(change.newDesc),
+			Env:   copyString(change.env),
+		})
+	case types.ACTION_UPDATE:
+		_, err = locker.UpdateSecret(change.key, change.env, &InputSecData{
+			Key:   copyString(change.newKey),
+			Value: copyString(change.newValue),
+			Desc:  copyString(change.newDesc),
+			Env:   copyString(change.newEnv),
+		})
+	case types.ACTION_DELETE:
+		err = locker.DeleteSecret(change.key, change.env)
+	}
+	return err
+}
+
+// rollback reverts the changes applied before failed using the state captured in previous, preceded by the failed
+// change itself when attempted and found applied on the server
+func (changeset *Changeset) rollback(result *ChangesetResult, previous []types.Secret, failed int, attempted bool) error {
+	var errs []error
+	start := failed - 1
+	if attempted {
+		applied, err := changeset.locker.changeApplied(changeset.changes[failed], previous[failed])
+		if err != nil {
+			result.Changes[failed].RollbackError = err
+			errs = append(errs, fmt.Errorf("error checking failed change %d (%s %s): %w", failed, changeset.changes[failed].action, changeset.changes[failed].key, err))
+		}
+		if applied {
+			result.Changes[failed].Applied = true
+			start = failed
+		}
+	}
+
+	for i := start; i >= 0; i-- {
+		change := changeset.changes[i]
+		var err error
+		switch change.action {
+		case types.ACTION_CREATE:
+			err = changeset.locker.DeleteSecret(change.key, change.env)
+		case types.ACTION_UPDATE:
+			// the secret may have been renamed or moved, find it where the update left it
+			key, env := change.key, change.env
+			if previous[i].EnvironmentHash == nil {
+				// the update fell back to ALL
+				env = nil
+			}
+			input := InputSecData{Value: copyString(&previous[i].Value), Desc: copyString(&previous[i].Description)}
+			if change.newKey != nil {
+				input.Key = copyString(&change.key)
+				key = *change.newKey
+			}
+			if change.newEnv != nil {
+				previousEnv := derefString(env)
+				input.Env = &previousEnv
+				env = nil
+				if *change.newEnv != "" {
+					env = copyString(change.newEnv)
+				}
+			}
+			_, err = changeset.locker.UpdateSecret(key, env, &input)
+		case types.ACTION_DELETE:
+			key := change.key
+			input := InputSecData{Key: &key, Value: copyString(&previous[i].Value), Desc: copyString(&previous[i].Description)}
+			if previous[i].EnvironmentHash != nil {
+				input.Env = copyString(change.env)
+			}
+			var restored types.EncryptedSecResponse
+			restored, err = changeset.locker.CreateSecret(&input)
+			result.Changes[i].RestoredID = restored.ID
+		}
+
+		if err != nil {
+			result.Changes[i].RollbackError = err
+			errs = append(errs, fmt.Errorf("error rolling back change %d (%s %s): %w", i, change.action, change.key, err))
+			continue
+		}
+		result.Changes[i].RolledBack = true
+	}
+
+	return errors.Join(errs...)
+}
+
+// changeApplied reads the server state of a failed change, bypassing the cache, to tell whether it took effect
+func (locker *Locker) changeApplied(change plannedChange, previous types.Secret) (bool, error) {
+	switch change.action {
+	case types.ACTION_CREATE:
+		hash, err := locker.getHash(change.key)
+		if err != nil {
+			return false, err
+		}
+		var envHash string
+		if change.env != nil {
+			envHash, err = locker.getHash(*change.env)
+			if err != nil {
+				return false, err
+			}
+		}
+
+		secObjs, err := locker.fetchServerSecrets(hash)
+		if err != nil {
+			return false, err
+		}
+		for _, secObj := range secObjs {
+			if derefString(secObj.EnvironmentHash) == envHash {
+				return true, nil
+			}
+		}
+		return false, nil
+
+	case types.ACTION_UPDATE:
+		hash := previous.SecretHash
+		if change.newKey != nil {
+			var err error
+			hash, err = locker.getHash(*change.newKey)
+			if err != nil {
+				return false, err
+			}
+		}
+
+		secObjs, err := locker.fetchServerSecrets(hash)
+		if err != nil {
+			return false, err
+		}
+		for _, secObj := range secObjs {
+			if secObj.ID == previous.ID {
+				return !sameRevision(secObj.RevisionDate, previous.RevisionDate), nil
+			}
+		}
+		return false, nil
+
+	case types.ACTION_DELETE:
+		secObjs, err := locker.fetchServerSecrets(previous.SecretHash)
+		if err != nil {
+			return false, err
+		}
+		for _, secObj := range secObjs {
+			if secObj.ID == previous.ID {
+				return false, nil
+			}
+		}
+
+		// gone from the server, drop the stale cached row before recreating it
+		result := locker.dBConn.Where("id = ?", previous.ID).Delete(&types.Secret{})
+		if result.Error != nil {
+			types.CURRENT_ERR = types.ERR_DB
+			return true, fmt.Errorf("error deleting cached secret: %w", result.Error)
+		}
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// fetchServerSecrets reads the secrets stored under hash from the server, bypassing the cooldown, and refreshes
+// their cached rows
+func (locker *Locker) fetchServerSecrets(hash string) ([]types.Secret, error) {
+	resBody, err := locker.fetchHashedItems(types.FETCH_KIND_SEC, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	secResponse, err := unmarshalAny[types.SecretResponse](resBody)
+	if err != nil {
+		return nil, err
+	}
+	return secResponse.Results, nil
+}
@@ -0,0 +1,294 @@
+package locker
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lockerpm/secrets-sdk-go/types"
+)
+
+// assertServerValue checks the value stored on the server for key in env (ALL when empty)
+func assertServerValue(t *testing.T, server *testServer, key, env, want string) {
+	t.Helper()
+
+	secret, ok := server.findSecret(key, env)
+	if !ok {
+		t.Fatalf("%s (%s) is missing on the server", key, env)
+	}
+	if got := server.decrypt(t, secret.Value); got != want {
+		t.Fatalf("%s (%s) broke, expecting %q on the server, getting %q instead", key, env, want, got)
+	}
+}
+
+func assertServerMissing(t *testing.T, server *testServer, key, env string) {
+	t.Helper()
+
+	if _, ok := server.findSecret(key, env); ok {
+		t.Fatalf("%s (%s) should not exist on the server", key, env)
+	}
+}
+
+func TestChangesetApplyInOrder(t *testing.T) {
+	server, client := newTestServer(t)
+	server.addEnvironment(t, "production")
+	host := server.addSecret(t, "DB_HOST", "db1", "production")
+	legacy := server.addSecret(t, "DB_LEGACY_URL", "mysql://db1", "production")
+
+	prod := "production"
+	result, err := client.NewChangeset().
+		Create(InputSecData{Key: stringPointer("DB_USER"), Value: stringPointer("app"), Env: &prod}).
+		Update("DB_HOST", &prod, InputSecData{Value: stringPointer("db2")}).
+		Delete("DB_LEGACY_URL", &prod).
+		Apply(context.Background())
+	if err != nil {
+		t.Fatalf("changeset broke, error: %v", err)
+	}
+
+	if result.Failed != -1 {
+		t.Fatalf("changeset broke, expecting no failure, getting %d", result.Failed)
+	}
+	for i, change := range result.Changes {
+		if !change.Applied || change.RolledBack || change.Error != nil {
+			t.Fatalf("changeset broke, change %d reported %+v", i, change)
+		}
+	}
+
+	want := []string{
+		"POST /v1/secrets",
+		"PUT /v1/secrets/" + host.ID,
+		"DELETE /v1/secrets/" + legacy.ID,
+	}
+	if calls := server.calls(); !reflect.DeepEqual(calls, want) {
+		t.Fatalf("changeset broke, expecting calls %v, getting %v instead", want, calls)
+	}
+
+	assertServerValue(t, server, "DB_USER", "production", "app")
+	assertServerValue(t, server, "DB_HOST", "production", "db2")
+	assertServerMissing(t, server, "DB_LEGACY_URL", "production")
+}
+
+func TestChangesetRollbackAfterFailure(t *testing.T) {
+	server, client := newTestServer(t)
+	server.addEnvironment(t, "production")
+	server.addSecret(t, "DB_HOST", "db1", "production")
+	legacy := server.addSecret(t, "DB_LEGACY_URL", "mysql://db1", "production")
+	password := server.addSecret(t, "DB_PASSWORD", "old-password", "production")
+
+	server.fail = func(method, path string, body []byte) (int, string) {
+		if method == http.MethodPut && path == "/v1/secrets/"+password.ID {
+			return http.StatusInternalServerError, "unavailable"
+		}
+		return 0, ""
+	}
+
+	prod := "production"
+	result, err := client.NewChangeset().
+		Update("DB_HOST", &prod, InputSecData{Value: stringPointer("db2")}).
+		Create(InputSecData{Key: stringPointer("DB_USER"), Value: stringPointer("app"), Env: &prod}).
+		Delete("DB_LEGACY_URL", &prod).
+		Update("DB_PASSWORD", &prod, InputSecData{Value: stringPointer("new-password")}).
+		Apply(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "change 3 (update DB_PASSWORD)") {
+		t.Fatalf("changeset broke, expecting the failure of change 3, getting %v", err)
+	}
+
+	if result.Failed != 3 || result.Changes[3].Error == nil || result.Changes[3].Applied {
+		t.Fatalf("changeset broke, change 3 reported %+v (failed: %d)", result.Changes[3], result.Failed)
+	}
+	for i := 0; i < 3; i++ {
+		if !result.Changes[i].Applied || !result.Changes[i].RolledBack || result.Changes[i].RollbackError != nil {
+			t.Fatalf("changeset broke, change %d reported %+v", i, result.Changes[i])
+		}
+	}
+
+	// rolled back in reverse order
+	calls := server.calls()
+	rollbackCalls := calls[len(calls)-3:]
+	if !strings.HasPrefix(rollbackCalls[0], "POST ") || !strings.HasPrefix(rollbackCalls[1], "DELETE ") || !strings.HasPrefix(rollbackCalls[2], "PUT ") {
+		t.Fatalf("changeset broke, expecting the rollback in reverse order, getting %v", calls)
+	}
+
+	assertServerValue(t, server, "DB_HOST", "production", "db1")
+	assertServerMissing(t, server, "DB_USER", "production")
+	assertServerValue(t, server, "DB_LEGACY_URL", "production", "mysql://db1")
+	assertServerValue(t, server, "DB_PASSWORD", "production", "old-password")
+
+	restored, _ := server.findSecret("DB_LEGACY_URL", "production")
+	if result.Changes[2].RestoredID == "" || result.Changes[2].RestoredID != restored.ID || restored.ID == legacy.ID {
+		t.Fatalf("changeset broke, expecting the new ID %q of the restored secret, getting %q", restored.ID, result.Changes[2].RestoredID)
+	}
+}
+
+func TestChangesetRollbackPartiallyAppliedFailure(t *testing.T) {
+	server, client := newTestServer(t)
+	server.addEnvironment(t, "production")
+	password := server.addSecret(t, "DB_PASSWORD", "old-password", "production")
+
+	// the update reaches the server, but the client gets an error
+	server.failAfter = func(method, path string, body []byte) (int, string) {
+		if method == http.MethodPut && path == "/v1/secrets/"+password.ID {
+			server.failAfter = nil
+			return http.StatusGatewayTimeout, "timeout"
+		}
+		return 0, ""
+	}
+
+	prod := "production"
+	result, err := client.NewChangeset().
+		Create(InputSecData{Key: stringPointer("DB_USER"), Value: stringPointer("app"), Env: &prod}).
+		Update("DB_PASSWORD", &prod, InputSecData{Value: stringPointer("new-password")}).
+		Apply(context.Background())
+	if err == nil {
+		t.Fatalf("changeset broke, expecting an error")
+	}
+
+	failed := result.Changes[1]
+	if result.Failed != 1 || !failed.Applied || !failed.RolledBack || failed.Error == nil {
+		t.Fatalf("changeset broke, the failed change reached the server and must be reverted, getting %+v", failed)
+	}
+	if !result.Changes[0].RolledBack {
+		t.Fatalf("changeset broke, change 0 reported %+v", result.Changes[0])
+	}
+
+	assertServerValue(t, server, "DB_PASSWORD", "production", "old-password")
+	assertServerMissing(t, server, "DB_USER", "production")
+}
+
+func TestChangesetRollbackPartiallyAppliedDelete(t *testing.T) {
+	server, client := newTestServer(t)
+	legacy := server.addSecret(t, "LEGACY_URL", "mysql://db1", "")
+
+	server.failAfter = func(method, path string, body []byte) (int, string) {
+		if method == http.MethodDelete && path == "/v1/secrets/"+legacy.ID {
+			server.failAfter = nil
+			return http.StatusBadGateway, "bad gateway"
+		}
+		return 0, ""
+	}
+
+	result, err := client.NewChangeset().Delete("LEGACY_URL", nil).Apply(context.Background())
+	if err == nil {
+		t.Fatalf("changeset broke, expecting an error")
+	}
+
+	change := result.Changes[0]
+	if !change.Applied || !change.RolledBack || change.RestoredID == "" {
+		t.Fatalf("changeset broke, the deletion reached the server and must be reverted, getting %+v", change)
+	}
+	assertServerValue(t, server, "LEGACY_URL", "", "mysql://db1")
+}
+
+func TestChangesetRollbackErrors(t *testing.T) {
+	server, client := newTestServer(t)
+	server.addSecret(t, "DB_HOST", "db1", "")
+
+	server.fail = func(method, path string, body []byte) (int, string) {
+		switch method {
+		case http.MethodPut:
+			return http.StatusInternalServerError, "unavailable"
+		case http.MethodDelete:
+			return http.StatusForbidden, "forbidden"
+		}
+		return 0, ""
+	}
+
+	result, err := client.NewChangeset().
+		Create(InputSecData{Key: stringPointer("DB_USER"), Value: stringPointer("app")}).
+		Update("DB_HOST", nil, InputSecData{Value: stringPointer("db2")}).
+		Apply(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "error rolling back change 0 (create DB_USER)") {
+		t.Fatalf("changeset broke, expecting the rollback error, getting %v", err)
+	}
+
+	created := result.Changes[0]
+	if !created.Applied || created.RolledBack || created.RollbackError == nil {
+		t.Fatalf("changeset broke, change 0 reported %+v", created)
+	}
+	if result.Changes[1].Applied || result.Changes[1].RolledBack {
+		t.Fatalf("changeset broke, change 1 was never applied, getting %+v", result.Changes[1])
+	}
+	assertServerValue(t, server, "DB_USER", "", "app")
+	assertServerValue(t, server, "DB_HOST", "", "db1")
+}
+
+func TestChangesetCanceled(t *testing.T) {
+	server, client := newTestServer(t)
+	server.addSecret(t, "DB_HOST", "db1", "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result, err := client.NewChangeset().
+		Update("DB_HOST", nil, InputSecData{Value: stringPointer("db2")}).
+		Apply(ctx)
+	if err == nil || result.Failed != 0 || result.Changes[0].Applied {
+		t.Fatalf("changeset broke, expecting nothing applied, getting %+v (%v)", result, err)
+	}
+	if calls := server.calls(); len(calls) != 0 {
+		t.Fatalf("changeset broke, expecting no call, getting %v", calls)
+	}
+}
+
+func TestDeleteSecretNotFound(t *testing.T) {
+	server, client := newTestServer(t)
+	secret := server.addSecret(t, "DB_HOST", "db1", "")
+
+	// a 404 on the delete endpoint is an error, the cached row is kept
+	server.fail = func(method, path string, body []byte) (int, string) {
+		return http.StatusNotFound, "not found"
+	}
+	err := client.DeleteSecret("DB_HOST", nil)
+	if err == nil || types.CURRENT_ERR != types.ERR_NOT_FOUND {
+		t.Fatalf("delete secret broke, a 404 must be reported, getting %v (%s)", err, types.CURRENT_ERR)
+	}
+	if calls := server.calls(); !reflect.DeepEqual(calls, []string{"DELETE /v1/secrets/" + secret.ID}) {
+		t.Fatalf("delete secret broke, getting calls %v", calls)
+	}
+
+	var count int64
+	client.dBConn.Model(&types.Secret{}).Where("id = ?", secret.ID).Count(&count)
+	if count != 1 {
+		t.Fatalf("delete secret broke, the cached row must be kept after a failed delete")
+	}
+
+	server.fail = nil
+	err = client.DeleteSecret("DB_HOST", nil)
+	if err != nil {
+		t.Fatalf("delete secret broke, error: %v", err)
+	}
+	assertServerMissing(t, server, "DB_HOST", "")
+	client.dBConn.Model(&types.Secret{}).Where("id = ?", secret.ID).Count(&count)
+	if count != 0 {
+		t.Fatalf("delete secret broke, the cached row must be deleted")
+	}
+}
+
+func TestFetchServerSecretsKeepsRevisionRow(t *testing.T) {
+	server, client := newTestServer(t)
+	server.addEnvironment(t, "production")
+	server.addSecret(t, "DB_PASSWORD", "password", "")
+	password := server.addSecret(t, "DB_PASSWORD", "old-password", "production")
+
+	_, err := client.GetSecret("DB_PASSWORD", nil)
+	if err != nil {
+		t.Fatalf("get secret broke, error: %v", err)
+	}
+
+	// rollback checks read single hashes, the next incremental sync must still start from the older revision
+	before := types.RevisionDate{ID: 0, RevisionDate: 1, LastCallSec: 100}
+	result := client.dBConn.Model(&types.RevisionDate{}).Where("id = ?", 0).
+		Updates(map[string]interface{}{"revision_date": before.RevisionDate, "last_call_sec": before.LastCallSec})
+	if result.Error != nil {
+		t.Fatalf("saving the revision row broke, error: %v", result.Error)
+	}
+
+	secObjs, err := client.fetchServerSecrets(password.SecretHash)
+	if err != nil || len(secObjs) != 2 {
+		t.Fatalf("fetch server secrets broke, expecting both secrets of the hash, getting %d (%v)", len(secObjs), err)
+	}
+	if after := revisionRow(t, client); after != before {
+		t.Fatalf("fetch server secrets broke, the revision row must be kept, expecting %+v, getting %+v", before, after)
+	}
+}
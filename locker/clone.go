@@ -0,0 +1,160 @@
+package locker
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/lockerpm/secrets-sdk-go/types"
+)
+
+type CloneOptions struct {
+	// Url and Desc override the source environment's external URL and description
+	Url  *string
+	Desc *string
+	// IncludeInherited also copies the ALL values the source environment does not override, so the new environment
+	// keeps them if ALL changes later
+	IncludeInherited bool
+}
+
+type CloneResult struct {
+	Environment types.EncryptedEnvResponse
+	// Keys lists the secrets created in the new environment, sorted
+	Keys []string
+}
+
+// CloneEnvironment creates the environment newName from source: its URL and description and every secret defined
+// in it. When a secret cannot be created, the secrets already created and the new environment are deleted, and the
+// returned result still holds the new environment and the keys copied before the failure.
+func (locker *Locker) CloneEnvironment(source, newName string, opts *CloneOptions) (CloneResult, error) {
+	if opts == nil {
+		opts = &CloneOptions{}
+	}
+
+	if newName == "" || newName == source {
+		types.CURRENT_ERR = types.ERR_INPUT
+		return CloneResult{}, fmt.Errorf("new environment's name must be set and differ from the source")
+	}
+
+	sourceEnv, err := locker.GetEnvironment(source)
+	if err != nil {
+		return CloneResult{}, err
+	}
+
+	secrets, err := locker.listScopedSecrets(&source)
+	if err != nil {
+		return CloneResult{}, err
+	}
+
+	if opts.IncludeInherited {
+		inherited, err := locker.listScopedSecrets(nil)
+		if err != nil {
+			return CloneResult{}, err
+		}
+		for key, secObj := range inherited {
+			if _, ok := secrets[key]; !ok {
+				secrets[key] = secObj
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(secrets))
+	for key := range secrets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	input := InputEnvData{Name: copyString(&newName)}
+	if sourceEnv.ExternalURL != "" {
+		input.Url = copyString(&sourceEnv.ExternalURL)
+	}
+	if sourceEnv.Description != "" {
+		input.Desc = copyString(&sourceEnv.Description)
+	}
+	if opts.Url != nil {
+		input.Url = copyString(opts.Url)
+	}
+	if opts.Desc != nil {
+		input.Desc = copyString(opts.Desc)
+	}
+
+	createResult, err := locker.CreateEnvironment(&input)
+	if err != nil {
+		return CloneResult{}, err
+	}
+	result := CloneResult{Environment: createResult}
+
+	// the new environment does not exist in dry-run mode, so the secret creations are planned here with the checks
+	// CreateSecret applies
+	if locker.DryRun {
+		envHash, err := locker.getHash(newName)
+		if err != nil {
+			return result, err
+		}
+		for _, key := range keys {
+			err := locker.planCloneSecret(key, secrets[key].Value, newName, envHash)
+			if err != nil {
+				return result, fmt.Errorf("error cloning %s: %w", key, err)
+			}
+			result.Keys = append(result.Keys, key)
+		}
+		return result, nil
+	}
+
+	for _, key := range keys {
+		secKey, value, env := key, secrets[key].Value, newName
+		secInput := InputSecData{Key: &secKey, Value: &value, Env: &env}
+		if secrets[key].Description != "" {
+			desc := secrets[key].Description
+			secInput.Desc = &desc
+		}
+
+		_, err := locker.CreateSecret(&secInput)
+		if err != nil {
+			err = fmt.Errorf("error cloning %s: %w", key, err)
+			return result, errors.Join(err, locker.rollbackClone(newName, result.Keys))
+		}
+		result.Keys = append(result.Keys, key)
+	}
+
+	return result, nil
+}
+
+// planCloneSecret records the creation of key in the environment name of hash envHash
+func (locker *Locker) planCloneSecret(key, value, name, envHash string) error {
+	locker.currentOperation = types.OPERATION_CREATE
+	err := locker.ValidateSecret(key, value, &name)
+	if err != nil {
+		return err
+	}
+
+	hash, err := locker.getHash(key)
+	if err != nil {
+		return err
+	}
+
+	err = locker.checkSecretDuplicate(hash, &envHash, "")
+	if err != nil {
+		return err
+	}
+
+	return locker.planCall(PlannedCall{Kind: types.FETCH_KIND_SEC, Name: key, Environment: name, Hash: hash}, nil)
+}
+
+// rollbackClone deletes the secrets created by a failed clone, then the environment
+func (locker *Locker) rollbackClone(name string, keys []string) error {
+	var errs []error
+	for i := len(keys) - 1; i >= 0; i-- {
+		err := locker.DeleteSecret(keys[i], &name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error rolling back %s: %w", keys[i], err))
+		}
+	}
+
+	err := locker.DeleteEnvironment(name)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("error rolling back environment %s: %w", name, err))
+	}
+
+	return errors.Join(errs...)
+}
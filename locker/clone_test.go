@@ -0,0 +1,91 @@
+package locker
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lockerpm/secrets-sdk-go/types"
+)
+
+func TestCloneEnvironmentPartialFailure(t *testing.T) {
+	server, client := newTestServer(t)
+	server.addEnvironment(t, "staging")
+	server.addSecret(t, "DB_HOST", "db1", "staging")
+	server.addSecret(t, "DB_PASSWORD", "s3cr3t", "staging")
+	server.addSecret(t, "DB_USER", "app", "staging")
+
+	// the second secret cannot be created
+	created := 0
+	server.fail = func(method, path string, body []byte) (int, string) {
+		if method == http.MethodPost && path == "/v1/secrets" {
+			created++
+			if created == 2 {
+				return http.StatusInternalServerError, "unavailable"
+			}
+		}
+		return 0, ""
+	}
+
+	result, err := client.CloneEnvironment("staging", "preview", nil)
+	if err == nil || !strings.Contains(err.Error(), "error cloning DB_PASSWORD") {
+		t.Fatalf("clone environment broke, expecting the failure of DB_PASSWORD, getting %v", err)
+	}
+	if result.Environment.ID == "" || !reflect.DeepEqual(result.Keys, []string{"DB_HOST"}) {
+		t.Fatalf("clone environment broke, expecting the new environment and the copied keys, getting %+v", result)
+	}
+
+	// rolled back
+	assertServerMissing(t, server, "DB_HOST", "preview")
+	if _, ok := server.environments[result.Environment.ID]; ok {
+		t.Fatalf("clone environment broke, the new environment must be deleted")
+	}
+}
+
+func TestCloneEnvironmentDryRunChecks(t *testing.T) {
+	server, client := newTestServer(t)
+	server.addEnvironment(t, "staging")
+	server.addSecret(t, "API_URL", "https://api.example.com", "staging")
+	server.addSecret(t, "DB_PASSWORD", "short", "staging")
+
+	client.DryRun = true
+	result, err := client.CloneEnvironment("staging", "preview", nil)
+	if err != nil {
+		t.Fatalf("dry run clone environment broke, error: %v", err)
+	}
+	if !reflect.DeepEqual(result.Keys, []string{"API_URL", "DB_PASSWORD"}) || len(client.DryRunPlan()) != 3 {
+		t.Fatalf("dry run clone environment broke, getting %+v (plan: %+v)", result, client.DryRunPlan())
+	}
+	if calls := server.calls(); len(calls) != 0 {
+		t.Fatalf("dry run clone environment broke, nothing must be sent, getting %v", calls)
+	}
+
+	// the validation rules apply to the planned secrets
+	err = client.AddValidationRules("DB_*", MinLength(12))
+	if err != nil {
+		t.Fatalf("adding validation rules broke, error: %v", err)
+	}
+	client.ClearDryRunPlan()
+	result, err = client.CloneEnvironment("staging", "preview", nil)
+	var validationErrs ValidationErrors
+	if !errors.As(err, &validationErrs) || validationErrs[0].Key != "DB_PASSWORD" || validationErrs[0].Environment != "preview" {
+		t.Fatalf("dry run clone environment broke, expecting a validation error, getting %v", err)
+	}
+	if !reflect.DeepEqual(result.Keys, []string{"API_URL"}) {
+		t.Fatalf("dry run clone environment broke, expecting the keys planned before the failure, getting %+v", result.Keys)
+	}
+	client.ClearValidationRules()
+
+	// a cached secret already using the key in the new environment is a duplicate
+	envHash := server.hash("preview")
+	err = client.dBConn.Create(&types.Secret{ID: "sec-stale", SecretHash: server.hash("API_URL"), EnvironmentHash: &envHash}).Error
+	if err != nil {
+		t.Fatalf("caching secret broke, error: %v", err)
+	}
+	_, err = client.CloneEnvironment("staging", "preview", nil)
+	if err == nil || !strings.Contains(err.Error(), types.SERVER_ERR_MSG_DUP) {
+		t.Fatalf("dry run clone environment broke, expecting a duplicate error, getting %v", err)
+	}
+}
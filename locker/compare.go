@@ -0,0 +1,82 @@
+package locker
+
+import (
+	"sort"
+)
+
+type ValueDrift struct {
+	Key          string `json:"key"`
+	FingerprintA string `json:"fingerprint_a"`
+	FingerprintB string `json:"fingerprint_b"`
+}
+
+// EnvDiff lists the drift between two environments, values are only reported as HMAC fingerprints
+type EnvDiff struct {
+	OnlyInA   []string     `json:"only_in_a"`
+	OnlyInB   []string     `json:"only_in_b"`
+	Different []ValueDrift `json:"different"`
+}
+
+// InSync reports whether both environments define the same keys with the same values
+func (diff EnvDiff) InSync() bool {
+	return len(diff.OnlyInA) == 0 && len(diff.OnlyInB) == 0 && len(diff.Different) == 0
+}
+
+// CompareEnvironments compares the secrets defined directly in a and b (nil means ALL), inherited values are ignored
+func (locker *Locker) CompareEnvironments(a, b *string) (EnvDiff, error) {
+	for _, env := range []*string{a, b} {
+		if env == nil {
+			continue
+		}
+		_, err := locker.GetEnvironment(*env)
+		if err != nil {
+			return EnvDiff{}, err
+		}
+	}
+
+	secretsA, err := locker.listScopedSecrets(a)
+	if err != nil {
+		return EnvDiff{}, err
+	}
+
+	secretsB, err := locker.listScopedSecrets(b)
+	if err != nil {
+		return EnvDiff{}, err
+	}
+
+	var diff EnvDiff
+	for key, secA := range secretsA {
+		secB, ok := secretsB[key]
+		if !ok {
+			diff.OnlyInA = append(diff.OnlyInA, key)
+			continue
+		}
+		if secA.Value == secB.Value {
+			continue
+		}
+
+		fingerprintA, err := locker.fingerprint(secA.Value)
+		if err != nil {
+			return EnvDiff{}, err
+		}
+		fingerprintB, err := locker.fingerprint(secB.Value)
+		if err != nil {
+			return EnvDiff{}, err
+		}
+		diff.Different = append(diff.Different, ValueDrift{Key: key, FingerprintA: fingerprintA, FingerprintB: fingerprintB})
+	}
+
+	for key := range secretsB {
+		if _, ok := secretsA[key]; !ok {
+			diff.OnlyInB = append(diff.OnlyInB, key)
+		}
+	}
+
+	sort.Strings(diff.OnlyInA)
+	sort.Strings(diff.OnlyInB)
+	sort.Slice(diff.Different, func(i, j int) bool {
+		return diff.Different[i].Key < diff.Different[j].Key
+	})
+
+	return diff, nil
+}
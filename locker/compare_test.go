@@ -0,0 +1,77 @@
+package locker
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompareEnvironments(t *testing.T) {
+	server, client := newTestServer(t)
+	server.addEnvironment(t, "staging")
+	server.addEnvironment(t, "production")
+	server.addSecret(t, "INHERITED", "shared", "")
+	server.addSecret(t, "SAME", "same-value", "staging")
+	server.addSecret(t, "SAME", "same-value", "production")
+	server.addSecret(t, "DB_PASSWORD", "staging-password", "staging")
+	server.addSecret(t, "DB_PASSWORD", "production-password", "production")
+	server.addSecret(t, "API_URL", "https://staging.example.com", "staging")
+	server.addSecret(t, "SENTRY_DSN", "https://sentry.example.com", "production")
+	server.addSecret(t, "CACHE_URL", "redis://production", "production")
+
+	staging, prod := "staging", "production"
+	diff, err := client.CompareEnvironments(&staging, &prod)
+	if err != nil {
+		t.Fatalf("compare environments broke, error: %v", err)
+	}
+
+	if !reflect.DeepEqual(diff.OnlyInA, []string{"API_URL"}) {
+		t.Fatalf("compare environments broke, expecting API_URL only in A, getting %v", diff.OnlyInA)
+	}
+	if !reflect.DeepEqual(diff.OnlyInB, []string{"CACHE_URL", "SENTRY_DSN"}) {
+		t.Fatalf("compare environments broke, expecting CACHE_URL and SENTRY_DSN only in B, getting %v", diff.OnlyInB)
+	}
+	if len(diff.Different) != 1 || diff.Different[0].Key != "DB_PASSWORD" {
+		t.Fatalf("compare environments broke, expecting DB_PASSWORD to differ, getting %+v", diff.Different)
+	}
+	drift := diff.Different[0]
+	if drift.FingerprintA == "" || drift.FingerprintA == drift.FingerprintB || diff.InSync() {
+		t.Fatalf("compare environments broke, getting %+v", drift)
+	}
+
+	// fingerprints are stable and never hold the values
+	data, err := json.Marshal(diff)
+	if err != nil {
+		t.Fatalf("compare environments broke, error: %v", err)
+	}
+	for _, value := range []string{"staging-password", "production-password", "same-value", "shared"} {
+		if strings.Contains(string(data), value) {
+			t.Fatalf("compare environments broke, the diff leaks %q: %s", value, data)
+		}
+	}
+	fingerprint, err := client.fingerprint("staging-password")
+	if err != nil || fingerprint != drift.FingerprintA {
+		t.Fatalf("compare environments broke, expecting the fingerprint %q, getting %q (%v)", fingerprint, drift.FingerprintA, err)
+	}
+
+	// inherited values are ignored, ALL is compared like any environment
+	diff, err = client.CompareEnvironments(nil, &prod)
+	if err != nil {
+		t.Fatalf("compare environments broke, error: %v", err)
+	}
+	if !reflect.DeepEqual(diff.OnlyInA, []string{"INHERITED"}) || len(diff.OnlyInB) != 4 || len(diff.Different) != 0 {
+		t.Fatalf("compare environments broke for ALL, getting %+v", diff)
+	}
+
+	diff, err = client.CompareEnvironments(&prod, &prod)
+	if err != nil || !diff.InSync() {
+		t.Fatalf("compare environments broke, an environment is in sync with itself, getting %+v (%v)", diff, err)
+	}
+
+	missing := "missing"
+	_, err = client.CompareEnvironments(&staging, &missing)
+	if err == nil {
+		t.Fatalf("compare environments broke, expecting an error for a missing environment")
+	}
+}
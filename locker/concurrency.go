@@ -0,0 +1,179 @@
+package locker
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/lockerpm/secrets-sdk-go/types"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RevisionConflictError is returned when an item changed on the server since the revision the caller read,
+// re-read the item and retry with CurrentRevision
+type RevisionConflictError struct {
+	Kind             string
+	ID               string
+	Name             string
+	ExpectedRevision float64
+	CurrentRevision  float64
+}
+
+func (conflictErr *RevisionConflictError) Error() string {
+	return fmt.Sprintf("%s %q was modified: expected revision %f, current revision %f",
+		conflictErr.Kind, conflictErr.Name, conflictErr.ExpectedRevision, conflictErr.CurrentRevision)
+}
+
+// UpdateSecretIfUnchanged is UpdateSecret failing with a *RevisionConflictError when the secret's revision date on the
+// server is not revisionDate. The check and the update are separate calls, so this narrows the window for lost
+// updates without closing it.
+func (locker *Locker) UpdateSecretIfUnchanged(key string, env *string, revisionDate float64, input *InputSecData) (types.EncryptedSecResponse, error) {
+	secObj, err := locker.GetSecret(key, env)
+	if err != nil {
+		return types.EncryptedSecResponse{}, err
+	}
+
+	current, err := locker.fetchItemRevision(types.FETCH_KIND_SEC, secObj.SecretHash, secObj.ID)
+	if err != nil {
+		return types.EncryptedSecResponse{}, err
+	}
+
+	if !sameRevision(current, revisionDate) {
+		types.CURRENT_ERR = types.ERR_CONFLICT
+		return types.EncryptedSecResponse{}, &RevisionConflictError{
+			Kind:             types.FETCH_KIND_SEC,
+			ID:               secObj.ID,
+			Name:             key,
+			ExpectedRevision: revisionDate,
+			CurrentRevision:  current,
+		}
+	}
+
+	return locker.UpdateSecret(key, env, input)
+}
+
+// UpdateEnvironmentIfUnchanged is UpdateEnvironment failing with a *RevisionConflictError when the environment's
+// revision date on the server is not revisionDate
+func (locker *Locker) UpdateEnvironmentIfUnchanged(name string, revisionDate float64, input *InputEnvData) (types.EncryptedEnvResponse, error) {
+	envObj, err := locker.GetEnvironment(name)
+	if err != nil {
+		return types.EncryptedEnvResponse{}, err
+	}
+
+	current, err := locker.fetchItemRevision(types.FETCH_KIND_ENV, envObj.Hash, envObj.ID)
+	if err != nil {
+		return types.EncryptedEnvResponse{}, err
+	}
+
+	if !sameRevision(current, revisionDate) {
+		types.CURRENT_ERR = types.ERR_CONFLICT
+		return types.EncryptedEnvResponse{}, &RevisionConflictError{
+			Kind:             types.FETCH_KIND_ENV,
+			ID:               envObj.ID,
+			Name:             name,
+			ExpectedRevision: revisionDate,
+			CurrentRevision:  current,
+		}
+	}
+
+	return locker.UpdateEnvironment(name, input)
+}
+
+// revisionTolerance absorbs the float64 rounding of the server's revision dates
+const revisionTolerance = 1e-6
+
+// sameRevision reports whether two revision dates read from the server are the same revision
+func sameRevision(a, b float64) bool {
+	return math.Abs(a-b) < revisionTolerance
+}
+
+// fetchItemRevision reads the revision date of item ID from the server, bypassing the cooldown, and refreshes
+// the cached items sharing its hash
+func (locker *Locker) fetchItemRevision(kind, hash, ID string) (float64, error) {
+	resBody, err := locker.fetchHashedItems(kind, hash)
+	if err != nil {
+		return 0, err
+	}
+
+	revisionDate, found := float64(0), false
+	switch kind {
+	case types.FETCH_KIND_SEC:
+		secResponse, err := unmarshalAny[types.SecretResponse](resBody)
+		if err != nil {
+			return 0, err
+		}
+		for _, secObj := range secResponse.Results {
+			if secObj.ID == ID {
+				revisionDate, found = secObj.RevisionDate, true
+			}
+		}
+	case types.FETCH_KIND_ENV:
+		envResponse, err := unmarshalAny[types.EnvironmentResponse](resBody)
+		if err != nil {
+			return 0, err
+		}
+		for _, envObj := range envResponse.Results {
+			if envObj.ID == ID {
+				revisionDate, found = envObj.RevisionDate, true
+			}
+		}
+	}
+
+	if !found {
+		types.CURRENT_ERR = types.ERR_NOT_FOUND
+		return 0, fmt.Errorf("%s no longer exists on the server", ID)
+	}
+
+	return revisionDate, nil
+}
+
+// fetchHashedItems reads the items stored under hash from the server, bypassing the cooldown, and caches them.
+// Unlike bulkUpdate it leaves the global revision row alone, a single hash says nothing about the other items.
+func (locker *Locker) fetchHashedItems(kind, hash string) ([]byte, error) {
+	var dataEndpoint string
+	switch kind {
+	case types.FETCH_KIND_SEC:
+		dataEndpoint = fmt.Sprintf("%s/v1/%s?count_secrets=1&page=1&paging=1&revision_date=0&size=2000&hash=%s", locker.APIBase, kind, hash)
+	case types.FETCH_KIND_ENV:
+		dataEndpoint = fmt.Sprintf("%s/v1/%s?count_environment=1&page=1&paging=1&revision_date=0&size=2000&hash=%s", locker.APIBase, kind, hash)
+	}
+
+	resBody, err := locker.httpActionIn(dataEndpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	var result *gorm.DB
+	switch kind {
+	case types.FETCH_KIND_SEC:
+		secResponse, err := unmarshalAny[types.SecretResponse](resBody)
+		if err != nil {
+			return nil, err
+		}
+		if len(secResponse.Results) == 0 {
+			return resBody, nil
+		}
+		result = locker.dBConn.Clauses(clause.OnConflict{
+			UpdateAll: true,
+		}).CreateInBatches(&secResponse.Results, 2000)
+	case types.FETCH_KIND_ENV:
+		envResponse, err := unmarshalAny[types.EnvironmentResponse](resBody)
+		if err != nil {
+			return nil, err
+		}
+		if len(envResponse.Results) == 0 {
+			return resBody, nil
+		}
+		result = locker.dBConn.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "hash"}},
+			UpdateAll: true,
+		}).CreateInBatches(&envResponse.Results, 2000)
+	}
+	if result.Error != nil {
+		types.CURRENT_ERR = types.ERR_DB
+		return nil, fmt.Errorf("error caching %s: %w", kind, result.Error)
+	}
+
+	return resBody, nil
+}
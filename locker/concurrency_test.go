@@ -0,0 +1,114 @@
+package locker
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lockerpm/secrets-sdk-go/types"
+)
+
+// revisionRow returns the cached global revision row
+func revisionRow(t *testing.T, client *Locker) types.RevisionDate {
+	t.Helper()
+
+	var revDate types.RevisionDate
+	result := client.dBConn.Where("id = ?", 0).Limit(1).Find(&revDate)
+	if result.Error != nil {
+		t.Fatalf("reading the revision row broke, error: %v", result.Error)
+	}
+	return revDate
+}
+
+func TestUpdateSecretIfUnchanged(t *testing.T) {
+	server, client := newTestServer(t)
+	secret := server.addSecret(t, "API_TOKEN", "old-token", "")
+	server.addSecret(t, "OTHER", "value", "")
+
+	_, err := client.GetSecret("API_TOKEN", nil)
+	if err != nil {
+		t.Fatalf("get secret broke, error: %v", err)
+	}
+
+	// the revision read back from JSON may be off by the float rounding
+	_, err = client.UpdateSecretIfUnchanged("API_TOKEN", nil, secret.RevisionDate+1e-9, &InputSecData{Value: stringPointer("new-token")})
+	if err != nil {
+		t.Fatalf("update secret if unchanged broke, error: %v", err)
+	}
+	assertServerValue(t, server, "API_TOKEN", "", "new-token")
+}
+
+func TestFetchItemRevisionKeepsRevisionRow(t *testing.T) {
+	server, client := newTestServer(t)
+	secret := server.addSecret(t, "API_TOKEN", "old-token", "")
+	server.addSecret(t, "OTHER", "value", "")
+
+	_, err := client.GetSecret("API_TOKEN", nil)
+	if err != nil {
+		t.Fatalf("get secret broke, error: %v", err)
+	}
+
+	// an older sync, the next incremental fetch must still start from it
+	before := types.RevisionDate{ID: 0, RevisionDate: 1, LastCallSec: 100}
+	result := client.dBConn.Model(&types.RevisionDate{}).Where("id = ?", 0).
+		Updates(map[string]interface{}{"revision_date": before.RevisionDate, "last_call_sec": before.LastCallSec})
+	if result.Error != nil {
+		t.Fatalf("saving the revision row broke, error: %v", result.Error)
+	}
+
+	server.describeSecret(t, secret.ID, "changed elsewhere")
+	changed, _ := server.findSecret("API_TOKEN", "")
+	current, err := client.fetchItemRevision(types.FETCH_KIND_SEC, secret.SecretHash, secret.ID)
+	if err != nil || current != changed.RevisionDate {
+		t.Fatalf("fetch item revision broke, expecting %f, getting %f (%v)", changed.RevisionDate, current, err)
+	}
+	if after := revisionRow(t, client); after != before {
+		t.Fatalf("fetch item revision broke, the revision row must be kept, expecting %+v, getting %+v", before, after)
+	}
+
+	var cached types.Secret
+	result = client.dBConn.Where("id = ?", secret.ID).First(&cached)
+	if result.Error != nil || cached.RevisionDate != changed.RevisionDate {
+		t.Fatalf("fetch item revision broke, expecting the cached row refreshed, getting %+v (%v)", cached, result.Error)
+	}
+}
+
+func TestUpdateSecretIfUnchangedConflict(t *testing.T) {
+	server, client := newTestServer(t)
+	secret := server.addSecret(t, "API_TOKEN", "old-token", "")
+
+	_, err := client.GetSecret("API_TOKEN", nil)
+	if err != nil {
+		t.Fatalf("get secret broke, error: %v", err)
+	}
+
+	// another client changes the secret after it was read
+	server.describeSecret(t, secret.ID, "changed elsewhere")
+	changed, _ := server.findSecret("API_TOKEN", "")
+
+	_, err = client.UpdateSecretIfUnchanged("API_TOKEN", nil, secret.RevisionDate, &InputSecData{Value: stringPointer("new-token")})
+	var conflictErr *RevisionConflictError
+	if !errors.As(err, &conflictErr) || types.CURRENT_ERR != types.ERR_CONFLICT {
+		t.Fatalf("update secret if unchanged broke, expecting a revision conflict, getting %v (%s)", err, types.CURRENT_ERR)
+	}
+	if conflictErr.ID != secret.ID || conflictErr.Name != "API_TOKEN" ||
+		conflictErr.ExpectedRevision != secret.RevisionDate || conflictErr.CurrentRevision != changed.RevisionDate {
+		t.Fatalf("update secret if unchanged broke, getting %+v", conflictErr)
+	}
+	assertServerValue(t, server, "API_TOKEN", "", "old-token")
+	if calls := server.calls(); len(calls) != 0 {
+		t.Fatalf("update secret if unchanged broke, nothing must be sent on conflict, getting %v", calls)
+	}
+
+	// retrying with the current revision goes through
+	_, err = client.UpdateSecretIfUnchanged("API_TOKEN", nil, conflictErr.CurrentRevision, &InputSecData{Value: stringPointer("new-token")})
+	if err != nil {
+		t.Fatalf("update secret if unchanged broke, error: %v", err)
+	}
+	assertServerValue(t, server, "API_TOKEN", "", "new-token")
+
+	server.removeSecret(secret.ID)
+	_, err = client.UpdateSecretIfUnchanged("API_TOKEN", nil, conflictErr.CurrentRevision, &InputSecData{Value: stringPointer("newer-token")})
+	if err == nil {
+		t.Fatalf("update secret if unchanged broke, expecting an error for a removed secret")
+	}
+}
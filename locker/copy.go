@@ -0,0 +1,145 @@
+package locker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lockerpm/secrets-sdk-go/types"
+)
+
+type CopyOptions struct {
+	// Keys are glob patterns of the keys to copy, every key is copied when empty
+	Keys []string
+	// OnConflict decides what happens to keys already defined in the target with a different value:
+	// types.CONFLICT_SKIP (default), types.CONFLICT_OVERWRITE or types.CONFLICT_FAIL
+	OnConflict string
+	// DryRun returns the plan without creating or updating anything
+	DryRun bool
+}
+
+type CopyResult struct {
+	Key     string
+	Action  string
+	Applied bool
+	Error   error
+}
+
+// CopySecrets copies the secrets defined directly in from to the environment to (nil means ALL) and returns
+// one result per selected key, sorted by key. With types.CONFLICT_FAIL nothing is written when a conflict exists.
+func (locker *Locker) CopySecrets(from, to *string, opts *CopyOptions) ([]CopyResult, error) {
+	if opts == nil {
+		opts = &CopyOptions{}
+	}
+
+	onConflict := opts.OnConflict
+	if onConflict == "" {
+		onConflict = types.CONFLICT_SKIP
+	}
+	if onConflict != types.CONFLICT_SKIP && onConflict != types.CONFLICT_OVERWRITE && onConflict != types.CONFLICT_FAIL {
+		types.CURRENT_ERR = types.ERR_INPUT
+		return nil, fmt.Errorf("invalid conflict policy %q", opts.OnConflict)
+	}
+
+	if (from == nil && to == nil) || (from != nil && to != nil && *from == *to) {
+		types.CURRENT_ERR = types.ERR_INPUT
+		return nil, fmt.Errorf("source and target environments must be different")
+	}
+
+	for _, env := range []*string{from, to} {
+		if env == nil {
+			continue
+		}
+		_, err := locker.GetEnvironment(*env)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	source, err := locker.listScopedSecrets(from)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := locker.listScopedSecrets(to)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(source))
+	for key := range source {
+		if len(opts.Keys) > 0 {
+			matched, err := matchAnyPattern(opts.Keys, key)
+			if err != nil {
+				return nil, err
+			}
+			if !matched {
+				continue
+			}
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	results := make([]CopyResult, 0, len(keys))
+	var conflicts []string
+	for _, key := range keys {
+		result := CopyResult{Key: key}
+		existing, ok := target[key]
+		switch {
+		case !ok:
+			result.Action = types.ACTION_CREATE
+		case existing.Value == source[key].Value && existing.Description == source[key].Description:
+			result.Action = types.ACTION_UNCHANGED
+		case onConflict == types.CONFLICT_OVERWRITE:
+			result.Action = types.ACTION_UPDATE
+		default:
+			result.Action = types.ACTION_SKIP
+			conflicts = append(conflicts, key)
+		}
+		results = append(results, result)
+	}
+
+	if onConflict == types.CONFLICT_FAIL && len(conflicts) > 0 {
+		types.CURRENT_ERR = types.ERR_INPUT
+		return results, fmt.Errorf("target environment already defines different values for: %s", strings.Join(conflicts, ", "))
+	}
+
+	if opts.DryRun {
+		return results, nil
+	}
+
+	failed := 0
+	for i := range results {
+		result := &results[i]
+		key := result.Key
+		value := source[key].Value
+		desc := source[key].Description
+
+		switch result.Action {
+		case types.ACTION_CREATE:
+			input := InputSecData{Key: &key, Value: &value, Desc: &desc}
+			if to != nil {
+				envName := *to
+				input.Env = &envName
+			}
+			_, result.Error = locker.CreateSecret(&input)
+		case types.ACTION_UPDATE:
+			_, result.Error = locker.UpdateSecret(key, to, &InputSecData{Value: &value, Desc: &desc})
+		default:
+			continue
+		}
+
+		if result.Error != nil {
+			failed++
+			continue
+		}
+		result.Applied = true
+	}
+
+	if failed > 0 {
+		return results, fmt.Errorf("%d of %d secrets failed to copy", failed, len(results))
+	}
+
+	return results, nil
+}
@@ -0,0 +1,130 @@
+package locker
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lockerpm/secrets-sdk-go/types"
+)
+
+// copyActions returns the key:action pairs of results
+func copyActions(results []CopyResult) []string {
+	var actions []string
+	for _, result := range results {
+		actions = append(actions, result.Key+":"+result.Action)
+	}
+	return actions
+}
+
+func TestCopySecrets(t *testing.T) {
+	staging, prod := "staging", "production"
+
+	tests := []struct {
+		name    string
+		opts    *CopyOptions
+		actions []string
+		err     string
+		// values expected in production afterwards
+		values map[string]string
+	}{
+		{
+			name:    "skip",
+			actions: []string{"API_URL:create", "DB_PASSWORD:skip", "SAME:unchanged"},
+			values:  map[string]string{"API_URL": "https://staging", "DB_PASSWORD": "production-password"},
+		},
+		{
+			name:    "overwrite",
+			opts:    &CopyOptions{OnConflict: types.CONFLICT_OVERWRITE},
+			actions: []string{"API_URL:create", "DB_PASSWORD:update", "SAME:unchanged"},
+			values:  map[string]string{"API_URL": "https://staging", "DB_PASSWORD": "staging-password"},
+		},
+		{
+			name:    "fail",
+			opts:    &CopyOptions{OnConflict: types.CONFLICT_FAIL},
+			actions: []string{"API_URL:create", "DB_PASSWORD:skip", "SAME:unchanged"},
+			err:     "target environment already defines different values for: DB_PASSWORD",
+			values:  map[string]string{"DB_PASSWORD": "production-password"},
+		},
+		{
+			name:    "dry run",
+			opts:    &CopyOptions{OnConflict: types.CONFLICT_OVERWRITE, DryRun: true},
+			actions: []string{"API_URL:create", "DB_PASSWORD:update", "SAME:unchanged"},
+			values:  map[string]string{"DB_PASSWORD": "production-password"},
+		},
+		{
+			name:    "keys",
+			opts:    &CopyOptions{Keys: []string{"API_*"}, OnConflict: types.CONFLICT_OVERWRITE},
+			actions: []string{"API_URL:create"},
+			values:  map[string]string{"API_URL": "https://staging", "DB_PASSWORD": "production-password"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, client := newTestServer(t)
+			server.addEnvironment(t, staging)
+			server.addEnvironment(t, prod)
+			server.addSecret(t, "API_URL", "https://staging", staging)
+			server.addSecret(t, "DB_PASSWORD", "staging-password", staging)
+			server.addSecret(t, "DB_PASSWORD", "production-password", prod)
+			server.addSecret(t, "SAME", "same", staging)
+			server.addSecret(t, "SAME", "same", prod)
+
+			results, err := client.CopySecrets(&staging, &prod, test.opts)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("copy secrets broke, expecting %q, getting %v", test.err, err)
+				}
+			} else if err != nil {
+				t.Fatalf("copy secrets broke, error: %v", err)
+			}
+
+			if actions := copyActions(results); !reflect.DeepEqual(actions, test.actions) {
+				t.Fatalf("copy secrets broke, expecting %v, getting %v instead", test.actions, actions)
+			}
+			for _, result := range results {
+				applied := test.err == "" && (test.opts == nil || !test.opts.DryRun) &&
+					(result.Action == types.ACTION_CREATE || result.Action == types.ACTION_UPDATE)
+				if result.Applied != applied || result.Error != nil {
+					t.Fatalf("copy secrets broke, %s reported %+v", result.Key, result)
+				}
+			}
+
+			for key, value := range test.values {
+				assertServerValue(t, server, key, prod, value)
+			}
+			if _, ok := test.values["API_URL"]; !ok {
+				assertServerMissing(t, server, "API_URL", prod)
+			}
+			if staging != "staging" || prod != "production" {
+				t.Fatalf("copy secrets broke, the environment names were modified")
+			}
+		})
+	}
+}
+
+func TestCopySecretsToAll(t *testing.T) {
+	server, client := newTestServer(t)
+	server.addEnvironment(t, "staging")
+	server.addSecret(t, "API_URL", "https://staging", "staging")
+
+	staging := "staging"
+	results, err := client.CopySecrets(&staging, nil, nil)
+	if err != nil || len(results) != 1 || !results[0].Applied {
+		t.Fatalf("copy secrets broke, getting %+v (%v)", results, err)
+	}
+	assertServerValue(t, server, "API_URL", "", "https://staging")
+
+	for _, opts := range []*CopyOptions{{OnConflict: "merge"}, {Keys: []string{"["}}} {
+		_, err = client.CopySecrets(&staging, nil, opts)
+		if err == nil || types.CURRENT_ERR != types.ERR_INPUT {
+			t.Fatalf("copy secrets broke, expecting an input error for %+v, getting %v", opts, err)
+		}
+	}
+
+	_, err = client.CopySecrets(&staging, &staging, nil)
+	if err == nil {
+		t.Fatalf("copy secrets broke, expecting an error copying an environment onto itself")
+	}
+}
@@ -109,7 +109,7 @@ func dataEncryption(rawData interface{}, symKey []byte, macKey []byte) error {
 			continue
 		}
 
-		if strings.EqualFold(value.Type().Field(i).Name, "UpdateEnv") || strings.EqualFold(value.Type().Field(i).Name, "EnvID") {
+		if strings.EqualFold(value.Type().Field(i).Name, "UpdateEnv") || strings.EqualFold(value.Type().Field(i).Name, "EnvID") || strings.EqualFold(value.Type().Field(i).Name, "Generate") {
 			continue
 		}
 
@@ -172,3 +172,11 @@ func dataEncryptionNested(value reflect.Value, symKey []byte, macKey []byte) err
 	}
 	return nil
 }
+
+// decryptString decrypts a single field, leaving values that are not encrypted strings untouched
+func decryptString(str string, symKey []byte, macKey []byte) (string, error) {
+	if len(str) == 0 || !encryptedStringPattern.MatchString(str) {
+		return str, nil
+	}
+	return aes256DecryptToString(str, symKey, macKey)
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 
 	"github.com/lockerpm/secrets-sdk-go/types"
@@ -45,3 +46,16 @@ func validateMac(encString string, macKey []byte) (bool, error) {
 
 	return hmac.Equal(macCodeBytes, calculatedMac), nil
 }
+
+// fingerprint returns a keyed HMAC-SHA256 digest of value, truncated to 16 bytes and hex encoded. It identifies
+// a value within the project without revealing it; locker.macKey must be loaded.
+func (locker *Locker) fingerprint(value string) (string, error) {
+	fingerprintKey, err := stretchKey(locker.macKey, "fingerprint")
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, fingerprintKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil)[:16]), nil
+}
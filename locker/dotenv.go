@@ -0,0 +1,349 @@
+package locker
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/lockerpm/secrets-sdk-go/types"
+)
+
+var dotenvKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.\-]*$`)
+
+type DotenvEntry struct {
+	Key   string
+	Value string
+	Line  int
+}
+
+type ImportOptions struct {
+	// DryRun computes the plan without creating or updating anything
+	DryRun bool
+}
+
+type ImportChange struct {
+	Key     string
+	Action  string
+	Line    int
+	Applied bool
+}
+
+type ImportPlan struct {
+	Changes []ImportChange
+}
+
+// String renders the plan as a diff without values: "+" create, "~" update, "=" unchanged
+func (plan ImportPlan) String() string {
+	var builder strings.Builder
+	for _, change := range plan.Changes {
+		marker := "="
+		switch change.Action {
+		case types.ACTION_CREATE:
+			marker = "+"
+		case types.ACTION_UPDATE:
+			marker = "~"
+		}
+		fmt.Fprintf(&builder, "%s %s (line %d)\n", marker, change.Key, change.Line)
+	}
+	return builder.String()
+}
+
+// ImportDotenv parses a .env file from r and creates or updates the secrets of env (ALL when env == nil)
+// accordingly. Keys defined several times keep their last value.
+func (locker *Locker) ImportDotenv(r io.Reader, env *string, opts *ImportOptions) (ImportPlan, error) {
+	if opts == nil {
+		opts = &ImportOptions{}
+	}
+
+	entries, err := ParseDotenv(r)
+	if err != nil {
+		return ImportPlan{}, err
+	}
+
+	existing, err := locker.listScopedSecrets(env)
+	if err != nil {
+		return ImportPlan{}, err
+	}
+
+	// last definition wins, the change keeps the position of the first one
+	var plan ImportPlan
+	values := make(map[string]string, len(entries))
+	position := make(map[string]int, len(entries))
+	for _, entry := range entries {
+		if idx, ok := position[entry.Key]; ok {
+			plan.Changes[idx].Line = entry.Line
+		} else {
+			position[entry.Key] = len(plan.Changes)
+			plan.Changes = append(plan.Changes, ImportChange{Key: entry.Key, Line: entry.Line})
+		}
+		values[entry.Key] = entry.Value
+	}
+
+	for i := range plan.Changes {
+		change := &plan.Changes[i]
+		secObj, ok := existing[change.Key]
+		switch {
+		case !ok:
+			change.Action = types.ACTION_CREATE
+		case secObj.Value != values[change.Key]:
+			change.Action = types.ACTION_UPDATE
+		default:
+			change.Action = types.ACTION_UNCHANGED
+		}
+	}
+
+	if opts.DryRun {
+		return plan, nil
+	}
+
+	for i := range plan.Changes {
+		change := &plan.Changes[i]
+		value := values[change.Key]
+
+		// the input is encrypted in place, it gets its own copies of the key and environment
+		switch change.Action {
+		case types.ACTION_CREATE:
+			_, err = locker.CreateSecret(&InputSecData{Key: copyString(&change.Key), Value: &value, Env: copyString(env)})
+		case types.ACTION_UPDATE:
+			_, err = locker.UpdateSecret(change.Key, env, &InputSecData{Value: &value})
+		default:
+			continue
+		}
+
+		if err != nil {
+			return plan, fmt.Errorf("error importing %s (line %d): %w", change.Key, change.Line, err)
+		}
+		change.Applied = true
+	}
+
+	return plan, nil
+}
+
+// ParseDotenv parses dotenv content: comments, optional "export" prefixes, single and double quoted values
+// (double quoted ones support \n \r \t \\ \" \$ escapes), multi-line quoted values and inline comments
+// after unquoted values
+func ParseDotenv(r io.Reader) ([]DotenvEntry, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		types.CURRENT_ERR = types.ERR_FILE
+		return nil, fmt.Errorf("error reading dotenv data: %w", err)
+	}
+
+	parser := dotenvParser{
+		src:  strings.ReplaceAll(string(data), "\r\n", "\n"),
+		line: 1,
+	}
+
+	entries, err := parser.parse()
+	if err != nil {
+		types.CURRENT_ERR = types.ERR_INPUT
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+type dotenvParser struct {
+	src  string
+	pos  int
+	line int
+}
+
+func (parser *dotenvParser) parse() ([]DotenvEntry, error) {
+	var entries []DotenvEntry
+	for {
+		parser.skipBlank()
+		if parser.eof() {
+			return entries, nil
+		}
+
+		if parser.peek() == '#' {
+			parser.skipLine()
+			continue
+		}
+
+		line := parser.line
+		key, err := parser.parseKey()
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := parser.parseValue()
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, DotenvEntry{Key: key, Value: value, Line: line})
+	}
+}
+
+func (parser *dotenvParser) parseKey() (string, error) {
+	start := parser.pos
+	for !parser.eof() && parser.peek() != '=' && parser.peek() != '\n' {
+		parser.pos++
+	}
+	if parser.eof() || parser.peek() != '=' {
+		return "", fmt.Errorf("line %d: missing \"=\" after key", parser.line)
+	}
+
+	key := strings.TrimSpace(parser.src[start:parser.pos])
+	if strings.HasPrefix(key, "export ") || strings.HasPrefix(key, "export\t") {
+		key = strings.TrimSpace(key[len("export"):])
+	}
+	if !dotenvKeyPattern.MatchString(key) {
+		return "", fmt.Errorf("line %d: invalid key %q", parser.line, key)
+	}
+
+	// consume "="
+	parser.pos++
+	return key, nil
+}
+
+func (parser *dotenvParser) parseValue() (string, error) {
+	start := parser.pos
+	for !parser.eof() && (parser.peek() == ' ' || parser.peek() == '\t') {
+		parser.pos++
+	}
+	if parser.eof() {
+		return "", nil
+	}
+
+	// "KEY= # comment" is an empty value
+	if parser.peek() == '#' && parser.pos > start {
+		parser.skipLine()
+		return "", nil
+	}
+
+	var value string
+	var err error
+	switch parser.peek() {
+	case '"':
+		value, err = parser.parseDoubleQuoted()
+	case '\'':
+		value, err = parser.parseSingleQuoted()
+	default:
+		return parser.parseUnquoted(), nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// only whitespace and a comment may follow a quoted value
+	for !parser.eof() && (parser.peek() == ' ' || parser.peek() == '\t') {
+		parser.pos++
+	}
+	switch {
+	case parser.eof(), parser.peek() == '\n':
+	case parser.peek() == '#':
+		parser.skipLine()
+	default:
+		return "", fmt.Errorf("line %d: unexpected character %q after quoted value", parser.line, parser.peek())
+	}
+
+	return value, nil
+}
+
+func (parser *dotenvParser) parseDoubleQuoted() (string, error) {
+	startLine := parser.line
+	parser.pos++
+
+	var builder strings.Builder
+	for !parser.eof() {
+		char := parser.peek()
+		parser.pos++
+		switch char {
+		case '"':
+			return builder.String(), nil
+		case '\n':
+			parser.line++
+			builder.WriteByte(char)
+		case '\\':
+			if parser.eof() {
+				continue
+			}
+			escaped := parser.peek()
+			parser.pos++
+			switch escaped {
+			case 'n':
+				builder.WriteByte('\n')
+			case 'r':
+				builder.WriteByte('\r')
+			case 't':
+				builder.WriteByte('\t')
+			case '\\', '"', '$':
+				builder.WriteByte(escaped)
+			case '\n':
+				// line continuation
+				parser.line++
+			default:
+				builder.WriteByte('\\')
+				builder.WriteByte(escaped)
+			}
+		default:
+			builder.WriteByte(char)
+		}
+	}
+
+	return "", fmt.Errorf("line %d: unterminated double quoted value", startLine)
+}
+
+func (parser *dotenvParser) parseSingleQuoted() (string, error) {
+	startLine := parser.line
+	parser.pos++
+
+	end := strings.IndexByte(parser.src[parser.pos:], '\'')
+	if end < 0 {
+		return "", fmt.Errorf("line %d: unterminated single quoted value", startLine)
+	}
+
+	value := parser.src[parser.pos : parser.pos+end]
+	parser.line += strings.Count(value, "\n")
+	parser.pos += end + 1
+	return value, nil
+}
+
+func (parser *dotenvParser) parseUnquoted() string {
+	start := parser.pos
+	for !parser.eof() && parser.peek() != '\n' {
+		parser.pos++
+	}
+	value := parser.src[start:parser.pos]
+
+	// a "#" starts a comment only when preceded by whitespace
+	for i := 1; i < len(value); i++ {
+		if value[i] == '#' && (value[i-1] == ' ' || value[i-1] == '\t') {
+			value = value[:i]
+			break
+		}
+	}
+
+	return strings.TrimSpace(value)
+}
+
+func (parser *dotenvParser) skipBlank() {
+	for !parser.eof() {
+		switch parser.peek() {
+		case '\n':
+			parser.line++
+		case ' ', '\t', '\r':
+		default:
+			return
+		}
+		parser.pos++
+	}
+}
+
+func (parser *dotenvParser) skipLine() {
+	for !parser.eof() && parser.peek() != '\n' {
+		parser.pos++
+	}
+}
+
+func (parser *dotenvParser) peek() byte {
+	return parser.src[parser.pos]
+}
+
+func (parser *dotenvParser) eof() bool {
+	return parser.pos >= len(parser.src)
+}
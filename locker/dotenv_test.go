@@ -0,0 +1,168 @@
+package locker
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDotenv(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []DotenvEntry
+	}{
+		{
+			name:  "plain",
+			input: "A=1\nB=two words\n",
+			want:  []DotenvEntry{{Key: "A", Value: "1", Line: 1}, {Key: "B", Value: "two words", Line: 2}},
+		},
+		{
+			name:  "comments and blank lines",
+			input: "# header\n\nA=1\n  # indented\nB=2",
+			want:  []DotenvEntry{{Key: "A", Value: "1", Line: 3}, {Key: "B", Value: "2", Line: 5}},
+		},
+		{
+			name:  "export prefix",
+			input: "export A=1\nexport\tB=2\nexported=3\n",
+			want:  []DotenvEntry{{Key: "A", Value: "1", Line: 1}, {Key: "B", Value: "2", Line: 2}, {Key: "exported", Value: "3", Line: 3}},
+		},
+		{
+			name:  "spaces around key and value",
+			input: "  A  =   1  \n",
+			want:  []DotenvEntry{{Key: "A", Value: "1", Line: 1}},
+		},
+		{
+			name:  "empty values",
+			input: "A=\nB= # comment\nC=\"\"\nD=''",
+			want:  []DotenvEntry{{Key: "A", Value: "", Line: 1}, {Key: "B", Value: "", Line: 2}, {Key: "C", Value: "", Line: 3}, {Key: "D", Value: "", Line: 4}},
+		},
+		{
+			name:  "inline comments",
+			input: "A=1 # one\nB=2\t# two\nC=3#not a comment\nD=\"4\" # four\nE='5'# five\n",
+			want: []DotenvEntry{
+				{Key: "A", Value: "1", Line: 1},
+				{Key: "B", Value: "2", Line: 2},
+				{Key: "C", Value: "3#not a comment", Line: 3},
+				{Key: "D", Value: "4", Line: 4},
+				{Key: "E", Value: "5", Line: 5},
+			},
+		},
+		{
+			name:  "double quoted escapes",
+			input: `A="line\nbreak\ttab\rcr \\ \" \$HOME \q"`,
+			want:  []DotenvEntry{{Key: "A", Value: "line\nbreak\ttab\rcr \\ \" $HOME \\q", Line: 1}},
+		},
+		{
+			name:  "single quoted values are literal",
+			input: `A='no \n escape "here" # nor comment'`,
+			want:  []DotenvEntry{{Key: "A", Value: `no \n escape "here" # nor comment`, Line: 1}},
+		},
+		{
+			name:  "multi-line double quoted",
+			input: "A=\"first\nsecond\"\nB=3\n",
+			want:  []DotenvEntry{{Key: "A", Value: "first\nsecond", Line: 1}, {Key: "B", Value: "3", Line: 3}},
+		},
+		{
+			name:  "multi-line single quoted",
+			input: "A='-----BEGIN KEY-----\nabc\n-----END KEY-----'\nB=3\n",
+			want:  []DotenvEntry{{Key: "A", Value: "-----BEGIN KEY-----\nabc\n-----END KEY-----", Line: 1}, {Key: "B", Value: "3", Line: 4}},
+		},
+		{
+			name:  "line continuation",
+			input: "A=\"one \\\ntwo\"\nB=3",
+			want:  []DotenvEntry{{Key: "A", Value: "one two", Line: 1}, {Key: "B", Value: "3", Line: 3}},
+		},
+		{
+			name:  "crlf line endings",
+			input: "A=1\r\nB=\"2\"\r\n",
+			want:  []DotenvEntry{{Key: "A", Value: "1", Line: 1}, {Key: "B", Value: "2", Line: 2}},
+		},
+		{
+			name:  "duplicate keys are all returned",
+			input: "A=1\nA=2\n",
+			want:  []DotenvEntry{{Key: "A", Value: "1", Line: 1}, {Key: "A", Value: "2", Line: 2}},
+		},
+		{
+			name:  "dotted and dashed keys",
+			input: "app.db-url=x\n",
+			want:  []DotenvEntry{{Key: "app.db-url", Value: "x", Line: 1}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entries, err := ParseDotenv(strings.NewReader(test.input))
+			if err != nil {
+				t.Fatalf("parse dotenv broke, error: %v", err)
+			}
+			if !reflect.DeepEqual(entries, test.want) {
+				t.Fatalf("parse dotenv broke, expecting %+v, getting %+v instead", test.want, entries)
+			}
+		})
+	}
+}
+
+func TestParseDotenvErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "missing equal sign", input: "A=1\nJUST_A_KEY\n", want: `line 2: missing "=" after key`},
+		{name: "missing equal sign at end", input: "A", want: `line 1: missing "=" after key`},
+		{name: "empty key", input: "=1", want: `line 1: invalid key ""`},
+		{name: "invalid key", input: "1A=1", want: `line 1: invalid key "1A"`},
+		{name: "key with space", input: "MY KEY=1", want: `line 1: invalid key "MY KEY"`},
+		{name: "unterminated double quote", input: "A=1\nB=\"open\n\n", want: "line 2: unterminated double quoted value"},
+		{name: "unterminated single quote", input: "A='open", want: "line 1: unterminated single quoted value"},
+		{name: "text after quoted value", input: `A="1"2`, want: `line 1: unexpected character '2' after quoted value`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseDotenv(strings.NewReader(test.input))
+			if err == nil {
+				t.Fatalf("parse dotenv broke, expecting error %q", test.want)
+			}
+			if err.Error() != test.want {
+				t.Fatalf("parse dotenv broke, expecting error %q, getting %q instead", test.want, err.Error())
+			}
+		})
+	}
+}
+
+func TestExportDotenvRoundTrip(t *testing.T) {
+	values := map[string]string{
+		"PLAIN":     "value",
+		"SPACES":    "  leading and trailing  ",
+		"QUOTES":    `it's "quoted"`,
+		"BACKSLASH": `C:\path\n`,
+		"DOLLAR":    "$HOME and ${PATH}",
+		"HASH":      "before # after",
+		"MULTILINE": "-----BEGIN KEY-----\nabc\r\n-----END KEY-----\n",
+		"TAB":       "a\tb",
+		"EMPTY":     "",
+		"UNICODE":   "mật khẩu ✓",
+	}
+
+	var buffer bytes.Buffer
+	err := exportDotenv(&buffer, values)
+	if err != nil {
+		t.Fatalf("export dotenv broke, error: %v", err)
+	}
+
+	entries, err := ParseDotenv(&buffer)
+	if err != nil {
+		t.Fatalf("parse dotenv broke, error: %v", err)
+	}
+
+	parsed := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		parsed[entry.Key] = entry.Value
+	}
+	if !reflect.DeepEqual(parsed, values) {
+		t.Fatalf("dotenv round trip broke, expecting %q, getting %q instead", values, parsed)
+	}
+}
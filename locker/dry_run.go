@@ -0,0 +1,108 @@
+package locker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/lockerpm/secrets-sdk-go/types"
+
+	"gorm.io/gorm"
+)
+
+// PlannedCall is an API call skipped in dry-run mode, values are never recorded
+type PlannedCall struct {
+	Operation string `json:"operation"`
+	Kind      string `json:"kind"`
+	Method    string `json:"method"`
+	Endpoint  string `json:"endpoint"`
+	// ItemID is empty for creations
+	ItemID string `json:"item_id,omitempty"`
+	// Name is the secret key or environment name after the call
+	Name string `json:"name"`
+	// Environment is the environment of a secret after the call, empty for ALL
+	Environment string `json:"environment,omitempty"`
+	Hash        string `json:"hash"`
+	// Fields lists the JSON fields of the request body
+	Fields []string `json:"fields"`
+}
+
+// DryRunPlan returns the calls skipped since dry-run mode was enabled, in order
+func (locker *Locker) DryRunPlan() []PlannedCall {
+	plan := make([]PlannedCall, len(locker.dryRunPlan))
+	copy(plan, locker.dryRunPlan)
+	return plan
+}
+
+// ClearDryRunPlan forgets the recorded calls
+func (locker *Locker) ClearDryRunPlan() {
+	locker.dryRunPlan = nil
+}
+
+// planCall records the call that would send body instead of sending it
+func (locker *Locker) planCall(call PlannedCall, body []byte) error {
+	if body != nil {
+		var fields map[string]json.RawMessage
+		err := json.Unmarshal(body, &fields)
+		if err != nil {
+			types.CURRENT_ERR = types.ERR_FUNC
+			return fmt.Errorf("error reading planned request: %w", err)
+		}
+
+		for field := range fields {
+			call.Fields = append(call.Fields, field)
+		}
+		sort.Strings(call.Fields)
+	}
+
+	call.Operation = locker.currentOperation
+	switch {
+	case locker.currentOperation == types.OPERATION_DELETE:
+		call.Method = http.MethodDelete
+	case call.ItemID != "":
+		call.Method = http.MethodPut
+	default:
+		call.Method = http.MethodPost
+	}
+	call.Endpoint = locker.itemEndpoint(call.Kind, call.ItemID)
+
+	locker.dryRunPlan = append(locker.dryRunPlan, call)
+	return nil
+}
+
+// checkSecretDuplicate fails like the server would when another cached secret uses secretHash in the environment
+// of hash envHash (ALL when nil), ID is the secret being updated
+func (locker *Locker) checkSecretDuplicate(secretHash string, envHash *string, ID string) error {
+	query := locker.dBConn.Where("secret_hash = ? AND id <> ?", secretHash, ID)
+	if envHash == nil {
+		query = query.Where("environment_hash is NULL")
+	} else {
+		query = query.Where("environment_hash = ?", *envHash)
+	}
+
+	var secObj types.Secret
+	result := query.First(&secObj)
+	return duplicateResult(result)
+}
+
+// checkEnvironmentDuplicate fails like the server would when another cached environment uses hash
+func (locker *Locker) checkEnvironmentDuplicate(hash string, ID string) error {
+	var envObj types.Environment
+	result := locker.dBConn.Where("hash = ? AND id <> ?", hash, ID).First(&envObj)
+	return duplicateResult(result)
+}
+
+func duplicateResult(result *gorm.DB) error {
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if result.Error != nil {
+		types.CURRENT_ERR = types.ERR_DB
+		return fmt.Errorf("error checking duplicates: %w", result.Error)
+	}
+
+	types.CURRENT_ERR = types.ERR_INPUT
+	return fmt.Errorf("dry run: %s", types.SERVER_ERR_MSG_DUP)
+}
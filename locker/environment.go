@@ -118,6 +118,16 @@ func (locker *Locker) ListEnvironment() ([]types.Environment, error) {
 }
 
 func (locker *Locker) CreateEnvironment(input *InputEnvData) (types.EncryptedEnvResponse, error) {
+	var name string
+	if input != nil {
+		name = derefString(input.Name)
+	}
+
+	createResult, err := locker.createEnvironment(input)
+	return createResult, locker.audit(types.OPERATION_CREATE, types.FETCH_KIND_ENV, createResult.ID, name, nil, err)
+}
+
+func (locker *Locker) createEnvironment(input *InputEnvData) (types.EncryptedEnvResponse, error) {
 	locker.currentOperation = types.OPERATION_CREATE
 	if input.Name == nil {
 		return types.EncryptedEnvResponse{}, fmt.Errorf("environment's name must not be empty")
@@ -128,6 +138,16 @@ func (locker *Locker) CreateEnvironment(input *InputEnvData) (types.EncryptedEnv
 		return types.EncryptedEnvResponse{}, err
 	}
 
+	var planned types.EncryptedEnvResponse
+	if locker.DryRun {
+		planned = types.EncryptedEnvResponse{
+			Name:        *input.Name,
+			Hash:        locker.hash,
+			ExternalURL: derefString(input.Url),
+			Description: derefString(input.Desc),
+		}
+	}
+
 	// namePreEnc := *input.Name
 	err = dataEncryption(input, locker.symKey, locker.macKey)
 	if err != nil {
@@ -140,6 +160,16 @@ func (locker *Locker) CreateEnvironment(input *InputEnvData) (types.EncryptedEnv
 		return types.EncryptedEnvResponse{}, err
 	}
 
+	if locker.DryRun {
+		err = locker.checkEnvironmentDuplicate(planned.Hash, "")
+		if err != nil {
+			return types.EncryptedEnvResponse{}, err
+		}
+
+		err = locker.planCall(PlannedCall{Kind: types.FETCH_KIND_ENV, Name: planned.Name, Hash: planned.Hash}, jsonBody)
+		return planned, err
+	}
+
 	createResult, err := createItem[types.EncryptedEnvResponse](locker, types.FETCH_KIND_ENV, jsonBody)
 	if err != nil {
 		return types.EncryptedEnvResponse{}, err
@@ -178,6 +208,11 @@ func (locker *Locker) CreateEnvironment(input *InputEnvData) (types.EncryptedEnv
 }
 
 func (locker *Locker) UpdateEnvironment(name string, input *InputEnvData) (types.EncryptedEnvResponse, error) {
+	editResult, err := locker.updateEnvironment(name, input)
+	return editResult, locker.audit(types.OPERATION_UPDATE, types.FETCH_KIND_ENV, editResult.ID, name, nil, err)
+}
+
+func (locker *Locker) updateEnvironment(name string, input *InputEnvData) (types.EncryptedEnvResponse, error) {
 	locker.currentOperation = types.OPERATION_UPDATE
 	if input == nil {
 		return types.EncryptedEnvResponse{}, fmt.Errorf("there must be atleast one field in update data")
@@ -202,6 +237,26 @@ func (locker *Locker) UpdateEnvironment(name string, input *InputEnvData) (types
 		namePreEnc = name
 	}
 
+	var planned types.EncryptedEnvResponse
+	if locker.DryRun {
+		planned = types.EncryptedEnvResponse{
+			Object:       getResult.Object,
+			ID:           getResult.ID,
+			Name:         namePreEnc,
+			ExternalURL:  getResult.ExternalURL,
+			Description:  getResult.Description,
+			CreationDate: getResult.CreationDate,
+			RevisionDate: getResult.RevisionDate,
+			ProjectID:    getResult.ProjectID,
+		}
+		if input.Url != nil {
+			planned.ExternalURL = *input.Url
+		}
+		if input.Desc != nil {
+			planned.Description = *input.Desc
+		}
+	}
+
 	err = dataEncryption(input, locker.symKey, locker.macKey)
 	if err != nil {
 		return types.EncryptedEnvResponse{}, err
@@ -217,6 +272,17 @@ func (locker *Locker) UpdateEnvironment(name string, input *InputEnvData) (types
 		return types.EncryptedEnvResponse{}, err
 	}
 
+	if locker.DryRun {
+		planned.Hash = input.Hash
+		err = locker.checkEnvironmentDuplicate(planned.Hash, envID)
+		if err != nil {
+			return types.EncryptedEnvResponse{}, err
+		}
+
+		err = locker.planCall(PlannedCall{Kind: types.FETCH_KIND_ENV, ItemID: envID, Name: planned.Name, Hash: planned.Hash}, jsonBody)
+		return planned, err
+	}
+
 	editResult, err := editItem[types.EncryptedEnvResponse](locker, types.FETCH_KIND_ENV, envID, jsonBody)
 	if err != nil {
 		return types.EncryptedEnvResponse{}, err
@@ -250,3 +316,28 @@ func (locker *Locker) UpdateEnvironment(name string, input *InputEnvData) (types
 
 	return *editResult, nil
 }
+
+// DeleteEnvironment deletes the environment name, its cached secrets are dropped as well
+func (locker *Locker) DeleteEnvironment(name string) error {
+	locker.currentOperation = types.OPERATION_DELETE
+
+	getResult, err := locker.GetEnvironment(name)
+	if err != nil {
+		return locker.audit(types.OPERATION_DELETE, types.FETCH_KIND_ENV, "", name, nil, err)
+	}
+
+	if locker.DryRun {
+		return locker.planCall(PlannedCall{Kind: types.FETCH_KIND_ENV, ItemID: getResult.ID, Name: name, Hash: getResult.Hash}, nil)
+	}
+
+	err = locker.deleteItem(types.FETCH_KIND_ENV, getResult.ID)
+	if err == nil {
+		result := locker.dBConn.Where("environment_hash = ?", getResult.Hash).Delete(&types.Secret{})
+		if result.Error != nil {
+			types.CURRENT_ERR = types.ERR_DB
+			err = fmt.Errorf("error deleting cached secrets: %w", result.Error)
+		}
+	}
+
+	return locker.audit(types.OPERATION_DELETE, types.FETCH_KIND_ENV, getResult.ID, name, nil, err)
+}
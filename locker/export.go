@@ -0,0 +1,360 @@
+package locker
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/lockerpm/secrets-sdk-go/types"
+)
+
+// ExportEntry is a flattened key/value pair handed to exporters
+type ExportEntry struct {
+	Key   string
+	Value string
+}
+
+// Exportable is implemented by results that know how to flatten themselves for exporters
+type Exportable interface {
+	ExportEntries() []ExportEntry
+}
+
+// ExportFunc writes result (any value accepted by ExportEntries, or anything for formats that
+// marshal the raw result) to w
+type ExportFunc func(w io.Writer, result interface{}) error
+
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+var envNameInvalidChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+var exportersMutex sync.RWMutex
+var exporters = map[string]ExportFunc{
+	types.FORMAT_JSON:       exportJSON,
+	types.FORMAT_TEXT:       exportText,
+	types.FORMAT_DOTENV:     exportDotenv,
+	types.FORMAT_SHELL:      exportShell,
+	types.FORMAT_YAML:       exportYAML,
+	types.FORMAT_DOCKER:     exportDocker,
+	types.FORMAT_SYSTEMD:    exportSystemd,
+	types.FORMAT_PROPERTIES: exportProperties,
+}
+
+// RegisterExportFormat registers (or replaces) the exporter used for format, format names are case-insensitive
+func RegisterExportFormat(format string, fn ExportFunc) {
+	exportersMutex.Lock()
+	defer exportersMutex.Unlock()
+	exporters[strings.ToLower(format)] = fn
+}
+
+// ExportFormats lists the registered format names, sorted
+func ExportFormats() []string {
+	exportersMutex.RLock()
+	defer exportersMutex.RUnlock()
+
+	formats := make([]string, 0, len(exporters))
+	for format := range exporters {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+func getExporter(format string) (ExportFunc, error) {
+	format = strings.ToLower(format)
+	if format == "" {
+		format = types.FORMAT_TEXT
+	}
+
+	exportersMutex.RLock()
+	defer exportersMutex.RUnlock()
+
+	fn, ok := exporters[format]
+	if !ok {
+		types.CURRENT_ERR = types.ERR_INPUT
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+	return fn, nil
+}
+
+// ExportEntries flattens the results returned by the SDK into key/value pairs: secrets export their key and value,
+// environments their name and external URL. Maps are sorted by key, slices keep their order.
+func ExportEntries(result interface{}) ([]ExportEntry, error) {
+	var entries []ExportEntry
+	switch resultAsserted := result.(type) {
+	case []ExportEntry:
+		entries = resultAsserted
+	case Exportable:
+		entries = resultAsserted.ExportEntries()
+	case []types.Secret:
+		for _, item := range resultAsserted {
+			entries = append(entries, ExportEntry{Key: item.Key, Value: item.Value})
+		}
+	case types.Secret:
+		entries = append(entries, ExportEntry{Key: resultAsserted.Key, Value: resultAsserted.Value})
+	case []types.Environment:
+		for _, item := range resultAsserted {
+			entries = append(entries, ExportEntry{Key: item.Name, Value: item.ExternalURL})
+		}
+	case types.Environment:
+		entries = append(entries, ExportEntry{Key: resultAsserted.Name, Value: resultAsserted.ExternalURL})
+	case types.EncryptedSecResponse:
+		entries = append(entries, ExportEntry{Key: resultAsserted.Key, Value: resultAsserted.Value})
+	case types.EncryptedEnvResponse:
+		entries = append(entries, ExportEntry{Key: resultAsserted.Name, Value: resultAsserted.ExternalURL})
+	case map[string]string:
+		keys := make([]string, 0, len(resultAsserted))
+		for key := range resultAsserted {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			entries = append(entries, ExportEntry{Key: key, Value: resultAsserted[key]})
+		}
+	default:
+		types.CURRENT_ERR = types.ERR_INPUT
+		return nil, fmt.Errorf("unsupported export data type %T", result)
+	}
+
+	return entries, nil
+}
+
+// exportEnvName keeps valid variable names as-is and normalizes the others ("db password" becomes "DB_PASSWORD")
+func exportEnvName(key string) (string, error) {
+	if envNamePattern.MatchString(key) {
+		return key, nil
+	}
+
+	name := envNameInvalidChars.ReplaceAllString(normalizeEnvName(key), "_")
+	if !envNamePattern.MatchString(name) {
+		types.CURRENT_ERR = types.ERR_INPUT
+		return "", fmt.Errorf("key %q cannot be exported as a variable name", key)
+	}
+	return name, nil
+}
+
+func exportJSON(w io.Writer, result interface{}) error {
+	data, err := types.MarshalIndentRevealed(result, "", "  ")
+	if err != nil {
+		types.CURRENT_ERR = types.ERR_FUNC
+		return fmt.Errorf("error marshalling data: %w", err)
+	}
+	data = append(data, '\n')
+
+	_, err = w.Write(data)
+	return err
+}
+
+func exportText(w io.Writer, result interface{}) error {
+	entries, err := ExportEntries(result)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		_, err = fmt.Fprintf(w, "%s = %s\n", entry.Key, entry.Value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportDotenv writes KEY="VALUE" lines, escaped so that ParseDotenv reads them back unchanged
+func exportDotenv(w io.Writer, result interface{}) error {
+	entries, err := ExportEntries(result)
+	if err != nil {
+		return err
+	}
+
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`, "$", `\$`)
+	for _, entry := range entries {
+		name, err := exportEnvName(entry.Key)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s=\"%s\"\n", name, replacer.Replace(entry.Value))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportShell writes POSIX "export KEY='VALUE'" lines
+func exportShell(w io.Writer, result interface{}) error {
+	entries, err := ExportEntries(result)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		name, err := exportEnvName(entry.Key)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "export %s='%s'\n", name, strings.ReplaceAll(entry.Value, "'", `'\''`))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func exportYAML(w io.Writer, result interface{}) error {
+	entries, err := ExportEntries(result)
+	if err != nil {
+		return err
+	}
+
+	if len(entries) == 0 {
+		_, err = io.WriteString(w, "{}\n")
+		return err
+	}
+
+	for _, entry := range entries {
+		_, err = fmt.Fprintf(w, "%s: %s\n", yamlQuote(entry.Key), yamlQuote(entry.Value))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportDocker writes the format read by `docker run --env-file`, which has no quoting nor multi-line support
+func exportDocker(w io.Writer, result interface{}) error {
+	entries, err := ExportEntries(result)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		name, err := exportEnvName(entry.Key)
+		if err != nil {
+			return err
+		}
+		if strings.ContainsAny(entry.Value, "\r\n") {
+			types.CURRENT_ERR = types.ERR_DATA
+			return fmt.Errorf("value of %s contains a newline, which docker env files cannot represent", entry.Key)
+		}
+		_, err = fmt.Fprintf(w, "%s=%s\n", name, entry.Value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportSystemd writes a systemd EnvironmentFile, values are double quoted
+func exportSystemd(w io.Writer, result interface{}) error {
+	entries, err := ExportEntries(result)
+	if err != nil {
+		return err
+	}
+
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	for _, entry := range entries {
+		name, err := exportEnvName(entry.Key)
+		if err != nil {
+			return err
+		}
+		if strings.ContainsAny(entry.Value, "\r\n") {
+			types.CURRENT_ERR = types.ERR_DATA
+			return fmt.Errorf("value of %s contains a newline, which systemd environment files cannot represent", entry.Key)
+		}
+		_, err = fmt.Fprintf(w, "%s=\"%s\"\n", name, replacer.Replace(entry.Value))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportProperties writes a Java .properties file, non-ASCII characters are written as \uXXXX escapes
+func exportProperties(w io.Writer, result interface{}) error {
+	entries, err := ExportEntries(result)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		_, err = fmt.Fprintf(w, "%s=%s\n", propertiesEscape(entry.Key, true), propertiesEscape(entry.Value, false))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func propertiesEscape(input string, isKey bool) string {
+	var builder strings.Builder
+	for i, char := range input {
+		switch char {
+		case '\\':
+			builder.WriteString(`\\`)
+		case '\n':
+			builder.WriteString(`\n`)
+		case '\r':
+			builder.WriteString(`\r`)
+		case '\t':
+			builder.WriteString(`\t`)
+		case '\f':
+			builder.WriteString(`\f`)
+		case '=', ':', '#', '!':
+			builder.WriteByte('\\')
+			builder.WriteRune(char)
+		case ' ':
+			// spaces are significant in keys and at the start of values
+			if isKey || i == 0 {
+				builder.WriteByte('\\')
+			}
+			builder.WriteRune(char)
+		default:
+			if char < 0x20 || char > 0x7e {
+				writeUnicodeEscape(&builder, char)
+				continue
+			}
+			builder.WriteRune(char)
+		}
+	}
+	return builder.String()
+}
+
+func writeUnicodeEscape(builder *strings.Builder, char rune) {
+	if char > 0xffff {
+		// properties files use UTF-16 surrogate pairs
+		char -= 0x10000
+		fmt.Fprintf(builder, `\u%04x\u%04x`, 0xd800+(char>>10), 0xdc00+(char&0x3ff))
+		return
+	}
+	fmt.Fprintf(builder, `\u%04x`, char)
+}
+
+// yamlQuote renders input as a double quoted YAML scalar
+func yamlQuote(input string) string {
+	var builder strings.Builder
+	builder.WriteByte('"')
+	for _, char := range input {
+		switch char {
+		case '\\':
+			builder.WriteString(`\\`)
+		case '"':
+			builder.WriteString(`\"`)
+		case '\n':
+			builder.WriteString(`\n`)
+		case '\r':
+			builder.WriteString(`\r`)
+		case '\t':
+			builder.WriteString(`\t`)
+		default:
+			if char < 0x20 || char == 0x7f {
+				fmt.Fprintf(&builder, `\x%02x`, char)
+				continue
+			}
+			builder.WriteRune(char)
+		}
+	}
+	builder.WriteByte('"')
+	return builder.String()
+}
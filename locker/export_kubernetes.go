@@ -0,0 +1,214 @@
+package locker
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/lockerpm/secrets-sdk-go/types"
+)
+
+const defaultKubernetesName = "locker-secrets"
+
+var kubernetesKeyInvalidChars = regexp.MustCompile(`[^-._a-zA-Z0-9]`)
+
+type KubernetesOptions struct {
+	// Name of the Secret (and ConfigMap), defaults to "locker-secrets"
+	Name        string
+	Namespace   string
+	Labels      map[string]string
+	Annotations map[string]string
+	// Format is types.FORMAT_YAML (default) or types.FORMAT_JSON
+	Format string
+	// ConfigMapKeys are glob patterns of non-sensitive keys rendered into a ConfigMap instead of the Secret
+	ConfigMapKeys []string
+	// ConfigMapName defaults to Name
+	ConfigMapName string
+}
+
+type kubernetesMetadata struct {
+	Name        string            `json:"name"`
+	Namespace   string            `json:"namespace,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type kubernetesObject struct {
+	APIVersion string             `json:"apiVersion"`
+	Kind       string             `json:"kind"`
+	Metadata   kubernetesMetadata `json:"metadata"`
+	Type       string             `json:"type,omitempty"`
+	Data       map[string]string  `json:"data"`
+}
+
+type kubernetesList struct {
+	APIVersion string             `json:"apiVersion"`
+	Kind       string             `json:"kind"`
+	Items      []kubernetesObject `json:"items"`
+}
+
+func init() {
+	RegisterExportFormat(types.FORMAT_KUBERNETES, func(w io.Writer, result interface{}) error {
+		return KubernetesManifest(w, result, nil)
+	})
+}
+
+// ExportKubernetes renders every secret of env (falling back to ALL) as a Kubernetes manifest
+func (locker *Locker) ExportKubernetes(w io.Writer, env *string, opts *KubernetesOptions) error {
+	secrets, err := locker.listRunSecrets(env)
+	if err != nil {
+		return err
+	}
+
+	values := make(map[string]string, len(secrets))
+	for key, secObj := range secrets {
+		values[key] = secObj.Value
+	}
+
+	return KubernetesManifest(w, values, opts)
+}
+
+// KubernetesManifest renders result (anything accepted by ExportEntries) as a v1 Secret with base64 data,
+// plus a ConfigMap when opts.ConfigMapKeys matches some keys. Keys are sorted so the output can be diffed.
+func KubernetesManifest(w io.Writer, result interface{}, opts *KubernetesOptions) error {
+	if opts == nil {
+		opts = &KubernetesOptions{}
+	}
+
+	entries, err := ExportEntries(result)
+	if err != nil {
+		return err
+	}
+
+	name := opts.Name
+	if name == "" {
+		name = defaultKubernetesName
+	}
+	configMapName := opts.ConfigMapName
+	if configMapName == "" {
+		configMapName = name
+	}
+
+	secretData := make(map[string]string)
+	configData := make(map[string]string)
+	seen := make(map[string]string)
+	for _, entry := range entries {
+		dataKey := kubernetesKeyInvalidChars.ReplaceAllString(entry.Key, "_")
+		if dataKey == "" {
+			types.CURRENT_ERR = types.ERR_INPUT
+			return fmt.Errorf("key %q cannot be used as a Kubernetes data key", entry.Key)
+		}
+		if original, ok := seen[dataKey]; ok {
+			types.CURRENT_ERR = types.ERR_INPUT
+			return fmt.Errorf("keys %q and %q both map to the Kubernetes data key %q", original, entry.Key, dataKey)
+		}
+		seen[dataKey] = entry.Key
+
+		isConfig, err := matchAnyPattern(opts.ConfigMapKeys, entry.Key)
+		if err != nil {
+			return err
+		}
+		if isConfig {
+			configData[dataKey] = entry.Value
+		} else {
+			secretData[dataKey] = base64.StdEncoding.EncodeToString([]byte(entry.Value))
+		}
+	}
+
+	objects := []kubernetesObject{{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Metadata: kubernetesMetadata{
+			Name:        name,
+			Namespace:   opts.Namespace,
+			Labels:      opts.Labels,
+			Annotations: opts.Annotations,
+		},
+		Type: "Opaque",
+		Data: secretData,
+	}}
+	if len(configData) > 0 {
+		objects = append(objects, kubernetesObject{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+			Metadata: kubernetesMetadata{
+				Name:        configMapName,
+				Namespace:   opts.Namespace,
+				Labels:      opts.Labels,
+				Annotations: opts.Annotations,
+			},
+			Data: configData,
+		})
+	}
+
+	switch strings.ToLower(opts.Format) {
+	case "", types.FORMAT_YAML:
+		return writeKubernetesYAML(w, objects)
+	case types.FORMAT_JSON:
+		var data []byte
+		if len(objects) == 1 {
+			data, err = json.MarshalIndent(objects[0], "", "  ")
+		} else {
+			data, err = json.MarshalIndent(kubernetesList{APIVersion: "v1", Kind: "List", Items: objects}, "", "  ")
+		}
+		if err != nil {
+			types.CURRENT_ERR = types.ERR_FUNC
+			return fmt.Errorf("error marshalling manifest: %w", err)
+		}
+		_, err = w.Write(append(data, '\n'))
+		return err
+	default:
+		types.CURRENT_ERR = types.ERR_INPUT
+		return fmt.Errorf("unsupported manifest format %q", opts.Format)
+	}
+}
+
+func writeKubernetesYAML(w io.Writer, objects []kubernetesObject) error {
+	var builder strings.Builder
+	for i, object := range objects {
+		if i > 0 {
+			builder.WriteString("---\n")
+		}
+		fmt.Fprintf(&builder, "apiVersion: %s\n", object.APIVersion)
+		fmt.Fprintf(&builder, "kind: %s\n", object.Kind)
+		builder.WriteString("metadata:\n")
+		fmt.Fprintf(&builder, "  name: %s\n", yamlQuote(object.Metadata.Name))
+		if object.Metadata.Namespace != "" {
+			fmt.Fprintf(&builder, "  namespace: %s\n", yamlQuote(object.Metadata.Namespace))
+		}
+		writeYAMLMap(&builder, "  ", "labels", object.Metadata.Labels)
+		writeYAMLMap(&builder, "  ", "annotations", object.Metadata.Annotations)
+		if object.Type != "" {
+			fmt.Fprintf(&builder, "type: %s\n", object.Type)
+		}
+		if len(object.Data) == 0 {
+			builder.WriteString("data: {}\n")
+			continue
+		}
+		writeYAMLMap(&builder, "", "data", object.Data)
+	}
+
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+func writeYAMLMap(builder *strings.Builder, indent, name string, values map[string]string) {
+	if len(values) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintf(builder, "%s%s:\n", indent, name)
+	for _, key := range keys {
+		fmt.Fprintf(builder, "%s  %s: %s\n", indent, yamlQuote(key), yamlQuote(values[key]))
+	}
+}
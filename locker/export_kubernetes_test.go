@@ -0,0 +1,116 @@
+package locker
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/lockerpm/secrets-sdk-go/types"
+)
+
+func TestExportKubernetes(t *testing.T) {
+	server, client := newTestServer(t)
+	server.addEnvironment(t, "production")
+	server.addSecret(t, "ZETA", "z", "")
+	server.addSecret(t, "LOG_LEVEL", "info", "")
+	server.addSecret(t, "LOG_LEVEL", "debug", "production")
+	server.addSecret(t, "DB_PASSWORD", "s3cr3t", "production")
+	server.addSecret(t, "A KEY", "a", "production")
+
+	prod := "production"
+	var output bytes.Buffer
+	err := client.ExportKubernetes(&output, &prod, &KubernetesOptions{
+		Name:          "web-secrets",
+		Namespace:     "prod",
+		Labels:        map[string]string{"tier": "backend", "app": "web"},
+		ConfigMapKeys: []string{"LOG_*"},
+	})
+	if err != nil {
+		t.Fatalf("kubernetes export broke, error: %v", err)
+	}
+
+	want := `apiVersion: v1
+kind: Secret
+metadata:
+  name: "web-secrets"
+  namespace: "prod"
+  labels:
+    "app": "web"
+    "tier": "backend"
+type: Opaque
+data:
+  "A_KEY": "YQ=="
+  "DB_PASSWORD": "czNjcjN0"
+  "ZETA": "eg=="
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: "web-secrets"
+  namespace: "prod"
+  labels:
+    "app": "web"
+    "tier": "backend"
+data:
+  "LOG_LEVEL": "debug"
+`
+	if output.String() != want {
+		t.Fatalf("kubernetes export broke, expecting\n%s\ngetting\n%s", want, output.String())
+	}
+
+	// the manifest is stable across exports
+	var again bytes.Buffer
+	err = client.ExportKubernetes(&again, &prod, &KubernetesOptions{
+		Name:          "web-secrets",
+		Namespace:     "prod",
+		Labels:        map[string]string{"app": "web", "tier": "backend"},
+		ConfigMapKeys: []string{"LOG_*"},
+	})
+	if err != nil || again.String() != want {
+		t.Fatalf("kubernetes export broke, the output must be sorted, getting\n%s (%v)", again.String(), err)
+	}
+}
+
+func TestKubernetesManifest(t *testing.T) {
+	var output bytes.Buffer
+	err := KubernetesManifest(&output, map[string]string{"B": "2", "A": "1"}, &KubernetesOptions{Format: types.FORMAT_JSON})
+	if err != nil {
+		t.Fatalf("kubernetes manifest broke, error: %v", err)
+	}
+
+	var object kubernetesObject
+	err = json.Unmarshal(output.Bytes(), &object)
+	if err != nil {
+		t.Fatalf("kubernetes manifest broke, invalid JSON: %v (%s)", err, output.String())
+	}
+	if object.Kind != "Secret" || object.Metadata.Name != defaultKubernetesName || object.Data["A"] != "MQ==" || object.Data["B"] != "Mg==" {
+		t.Fatalf("kubernetes manifest broke, getting %+v", object)
+	}
+
+	output.Reset()
+	err = KubernetesManifest(&output, map[string]string{}, nil)
+	if err != nil || !strings.HasSuffix(output.String(), "type: Opaque\ndata: {}\n") {
+		t.Fatalf("kubernetes manifest broke for no secret, getting %s (%v)", output.String(), err)
+	}
+
+	tests := []struct {
+		name   string
+		values map[string]string
+		opts   *KubernetesOptions
+		err    string
+	}{
+		{name: "collision", values: map[string]string{"a b": "1", "a_b": "2"}, err: `both map to the Kubernetes data key "a_b"`},
+		{name: "format", values: map[string]string{"A": "1"}, opts: &KubernetesOptions{Format: "toml"}, err: `unsupported manifest format "toml"`},
+		{name: "pattern", values: map[string]string{"A": "1"}, opts: &KubernetesOptions{ConfigMapKeys: []string{"["}}, err: "invalid pattern"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := KubernetesManifest(&bytes.Buffer{}, test.values, test.opts)
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("kubernetes manifest broke, expecting %q, getting %v", test.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package locker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/lockerpm/secrets-sdk-go/types"
+)
+
+const defaultGeneratedLength = 32
+
+const lowercaseChars = "abcdefghijklmnopqrstuvwxyz"
+const uppercaseChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+const digitChars = "0123456789"
+const symbolChars = "!#$%&*+-=?@^_~"
+const ambiguousChars = "0O1Il|"
+
+// SecretPolicy describes a generated value
+type SecretPolicy struct {
+	// Format is types.GEN_FORMAT_CHARS (default), types.GEN_FORMAT_HEX or types.GEN_FORMAT_BASE64URL
+	Format string
+	// Length is a number of characters for the chars format and a number of random bytes for the others,
+	// defaults to 32
+	Length int
+
+	// character classes of the chars format, letters and digits are used when none is set
+	Lowercase bool
+	Uppercase bool
+	Digits    bool
+	Symbols   bool
+	// RequireEachClass guarantees at least one character of every enabled class
+	RequireEachClass bool
+	// ExcludeAmbiguous drops characters that are easily confused (0 O 1 I l |)
+	ExcludeAmbiguous bool
+	// ExcludeChars drops any other character
+	ExcludeChars string
+}
+
+// GenerateSecretValue generates a value following policy using crypto/rand
+func GenerateSecretValue(policy SecretPolicy) (string, error) {
+	length := policy.Length
+	if length == 0 {
+		length = defaultGeneratedLength
+	}
+	if length < 0 {
+		types.CURRENT_ERR = types.ERR_INPUT
+		return "", fmt.Errorf("generated value length must be positive")
+	}
+
+	switch policy.Format {
+	case "", types.GEN_FORMAT_CHARS:
+		return generateChars(policy, length)
+	case types.GEN_FORMAT_HEX:
+		token, err := randomBytes(length)
+		if err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(token), nil
+	case types.GEN_FORMAT_BASE64URL:
+		token, err := randomBytes(length)
+		if err != nil {
+			return "", err
+		}
+		return base64.RawURLEncoding.EncodeToString(token), nil
+	default:
+		types.CURRENT_ERR = types.ERR_INPUT
+		return "", fmt.Errorf("unsupported generated value format %q", policy.Format)
+	}
+}
+
+// PolicyGenerator turns policy into a ValueGenerator for RotateSecret
+func PolicyGenerator(policy SecretPolicy) ValueGenerator {
+	return func(_ context.Context, _ types.Secret) (string, error) {
+		return GenerateSecretValue(policy)
+	}
+}
+
+// ValueGenerator produces a new value for a secret, current is the secret being replaced
+type ValueGenerator func(ctx context.Context, current types.Secret) (string, error)
+
+// RandomPassword generates passwords of length characters drawn uniformly from letters, digits and symbols
+func RandomPassword(length int) ValueGenerator {
+	generate := PolicyGenerator(SecretPolicy{
+		Length:    length,
+		Lowercase: true,
+		Uppercase: true,
+		Digits:    true,
+		Symbols:   true,
+	})
+	return func(ctx context.Context, current types.Secret) (string, error) {
+		if length <= 0 {
+			types.CURRENT_ERR = types.ERR_INPUT
+			return "", fmt.Errorf("password length must be positive")
+		}
+		return generate(ctx, current)
+	}
+}
+
+// HexToken generates hex encoded tokens of numBytes random bytes
+func HexToken(numBytes int) ValueGenerator {
+	generate := PolicyGenerator(SecretPolicy{Format: types.GEN_FORMAT_HEX, Length: numBytes})
+	return func(ctx context.Context, current types.Secret) (string, error) {
+		if numBytes <= 0 {
+			types.CURRENT_ERR = types.ERR_INPUT
+			return "", fmt.Errorf("token size must be positive")
+		}
+		return generate(ctx, current)
+	}
+}
+
+// UUID generates random (version 4) UUIDs
+func UUID() ValueGenerator {
+	return func(_ context.Context, _ types.Secret) (string, error) {
+		uuid, err := randomBytes(16)
+		if err != nil {
+			return "", err
+		}
+		uuid[6] = (uuid[6] & 0x0f) | 0x40
+		uuid[8] = (uuid[8] & 0x3f) | 0x80
+
+		return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:16]), nil
+	}
+}
+
+func generateChars(policy SecretPolicy, length int) (string, error) {
+	var classes []string
+	if policy.Lowercase {
+		classes = append(classes, lowercaseChars)
+	}
+	if policy.Uppercase {
+		classes = append(classes, uppercaseChars)
+	}
+	if policy.Digits {
+		classes = append(classes, digitChars)
+	}
+	if policy.Symbols {
+		classes = append(classes, symbolChars)
+	}
+	if len(classes) == 0 {
+		classes = []string{lowercaseChars, uppercaseChars, digitChars}
+	}
+
+	excluded := policy.ExcludeChars
+	if policy.ExcludeAmbiguous {
+		excluded += ambiguousChars
+	}
+
+	// classes left empty by the exclusions only fail when each class is required
+	var alphabet string
+	var remaining []string
+	for _, class := range classes {
+		class = strings.Map(func(char rune) rune {
+			if strings.ContainsRune(excluded, char) {
+				return -1
+			}
+			return char
+		}, class)
+		if class == "" {
+			if policy.RequireEachClass {
+				types.CURRENT_ERR = types.ERR_INPUT
+				return "", fmt.Errorf("every character of a required class is excluded")
+			}
+			continue
+		}
+		remaining = append(remaining, class)
+		alphabet += class
+	}
+	classes = remaining
+	if alphabet == "" {
+		types.CURRENT_ERR = types.ERR_INPUT
+		return "", fmt.Errorf("every character is excluded")
+	}
+
+	if policy.RequireEachClass && length < len(classes) {
+		types.CURRENT_ERR = types.ERR_INPUT
+		return "", fmt.Errorf("length %d is too short to include %d character classes", length, len(classes))
+	}
+
+	value := make([]byte, 0, length)
+	if policy.RequireEachClass {
+		for _, class := range classes {
+			char, err := randomChar(class)
+			if err != nil {
+				return "", err
+			}
+			value = append(value, char)
+		}
+	}
+	for len(value) < length {
+		char, err := randomChar(alphabet)
+		if err != nil {
+			return "", err
+		}
+		value = append(value, char)
+	}
+
+	// shuffle so the required characters are not always first
+	for i := len(value) - 1; i > 0; i-- {
+		j, err := randomInt(i + 1)
+		if err != nil {
+			return "", err
+		}
+		value[i], value[j] = value[j], value[i]
+	}
+
+	return string(value), nil
+}
+
+func randomChar(alphabet string) (byte, error) {
+	idx, err := randomInt(len(alphabet))
+	if err != nil {
+		return 0, err
+	}
+	return alphabet[idx], nil
+}
+
+func randomInt(max int) (int, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max)))
+	if err != nil {
+		types.CURRENT_ERR = types.ERR_FUNC
+		return 0, fmt.Errorf("error generating random value: %w", err)
+	}
+	return int(n.Int64()), nil
+}
+
+func randomBytes(size int) ([]byte, error) {
+	data := make([]byte, size)
+	_, err := rand.Read(data)
+	if err != nil {
+		types.CURRENT_ERR = types.ERR_FUNC
+		return nil, fmt.Errorf("error generating random value: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,219 @@
+package locker
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/lockerpm/secrets-sdk-go/types"
+)
+
+func TestGenerateSecretValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   SecretPolicy
+		length   int
+		alphabet string
+	}{
+		{
+			name:     "default",
+			policy:   SecretPolicy{},
+			length:   defaultGeneratedLength,
+			alphabet: lowercaseChars + uppercaseChars + digitChars,
+		},
+		{
+			name:     "lowercase only",
+			policy:   SecretPolicy{Length: 50, Lowercase: true},
+			length:   50,
+			alphabet: lowercaseChars,
+		},
+		{
+			name:     "digits and symbols",
+			policy:   SecretPolicy{Length: 64, Digits: true, Symbols: true},
+			length:   64,
+			alphabet: digitChars + symbolChars,
+		},
+		{
+			name:     "every class",
+			policy:   SecretPolicy{Length: 1, Lowercase: true, Uppercase: true, Digits: true, Symbols: true},
+			length:   1,
+			alphabet: lowercaseChars + uppercaseChars + digitChars + symbolChars,
+		},
+		{
+			name:     "exclude ambiguous",
+			policy:   SecretPolicy{Length: 200, Lowercase: true, Uppercase: true, Digits: true, ExcludeAmbiguous: true},
+			length:   200,
+			alphabet: "abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789",
+		},
+		{
+			name:     "exclude chars",
+			policy:   SecretPolicy{Length: 200, Digits: true, ExcludeChars: "13579"},
+			length:   200,
+			alphabet: "02468",
+		},
+		{
+			name:     "class emptied by exclusions is dropped when not required",
+			policy:   SecretPolicy{Length: 100, Lowercase: true, Digits: true, ExcludeChars: digitChars},
+			length:   100,
+			alphabet: lowercaseChars,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value, err := GenerateSecretValue(test.policy)
+			if err != nil {
+				t.Fatalf("generate secret value broke, error: %v", err)
+			}
+			if len(value) != test.length {
+				t.Fatalf("generate secret value broke, expecting length %d, getting %d instead", test.length, len(value))
+			}
+			for _, char := range value {
+				if !strings.ContainsRune(test.alphabet, char) {
+					t.Fatalf("generate secret value broke, %q is not in %q", char, test.alphabet)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateSecretValueRequireEachClass(t *testing.T) {
+	policy := SecretPolicy{Length: 4, Lowercase: true, Uppercase: true, Digits: true, Symbols: true, RequireEachClass: true, ExcludeAmbiguous: true}
+	classes := []string{lowercaseChars, uppercaseChars, digitChars, symbolChars}
+
+	// with length == number of classes, every value must hold exactly one character of each class
+	for i := 0; i < 200; i++ {
+		value, err := GenerateSecretValue(policy)
+		if err != nil {
+			t.Fatalf("generate secret value broke, error: %v", err)
+		}
+		for _, class := range classes {
+			if !strings.ContainsAny(value, class) {
+				t.Fatalf("generate secret value broke, %q has no character of %q", value, class)
+			}
+		}
+		if strings.ContainsAny(value, ambiguousChars) {
+			t.Fatalf("generate secret value broke, %q has an ambiguous character", value)
+		}
+	}
+}
+
+func TestGenerateSecretValueFormats(t *testing.T) {
+	value, err := GenerateSecretValue(SecretPolicy{Format: types.GEN_FORMAT_HEX, Length: 16})
+	if err != nil {
+		t.Fatalf("generate hex value broke, error: %v", err)
+	}
+	decoded, err := hex.DecodeString(value)
+	if err != nil || len(decoded) != 16 {
+		t.Fatalf("generate hex value broke, getting %q", value)
+	}
+
+	value, err = GenerateSecretValue(SecretPolicy{Format: types.GEN_FORMAT_BASE64URL})
+	if err != nil {
+		t.Fatalf("generate base64url value broke, error: %v", err)
+	}
+	decoded, err = base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(decoded) != defaultGeneratedLength {
+		t.Fatalf("generate base64url value broke, getting %q", value)
+	}
+}
+
+func TestGenerateSecretValueErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy SecretPolicy
+		want   string
+	}{
+		{
+			name:   "negative length",
+			policy: SecretPolicy{Length: -1},
+			want:   "generated value length must be positive",
+		},
+		{
+			name:   "unknown format",
+			policy: SecretPolicy{Format: "base32"},
+			want:   `unsupported generated value format "base32"`,
+		},
+		{
+			name:   "required class excluded",
+			policy: SecretPolicy{Lowercase: true, Digits: true, RequireEachClass: true, ExcludeChars: digitChars},
+			want:   "every character of a required class is excluded",
+		},
+		{
+			name:   "everything excluded",
+			policy: SecretPolicy{Digits: true, ExcludeChars: digitChars},
+			want:   "every character is excluded",
+		},
+		{
+			name:   "too short for the required classes",
+			policy: SecretPolicy{Length: 3, Lowercase: true, Uppercase: true, Digits: true, Symbols: true, RequireEachClass: true},
+			want:   "length 3 is too short to include 4 character classes",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := GenerateSecretValue(test.policy)
+			if err == nil || err.Error() != test.want {
+				t.Fatalf("generate secret value broke, expecting error %q, getting %v instead", test.want, err)
+			}
+		})
+	}
+}
+
+func TestBuiltinGenerators(t *testing.T) {
+	ctx := context.Background()
+	passwordPattern := regexp.MustCompile("^[" + regexp.QuoteMeta(lowercaseChars+uppercaseChars+digitChars+symbolChars) + "]+$")
+
+	for _, length := range []int{1, 3, 32} {
+		password, err := RandomPassword(length)(ctx, types.Secret{})
+		if err != nil {
+			t.Fatalf("random password broke, error: %v", err)
+		}
+		if len(password) != length || !passwordPattern.MatchString(password) {
+			t.Fatalf("random password broke, getting %q for length %d", password, length)
+		}
+	}
+
+	token, err := HexToken(8)(ctx, types.Secret{})
+	if err != nil {
+		t.Fatalf("hex token broke, error: %v", err)
+	}
+	if len(token) != 16 {
+		t.Fatalf("hex token broke, getting %q", token)
+	}
+
+	uuid, err := UUID()(ctx, types.Secret{})
+	if err != nil {
+		t.Fatalf("uuid broke, error: %v", err)
+	}
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(uuid) {
+		t.Fatalf("uuid broke, getting %q", uuid)
+	}
+}
+
+func TestBuiltinGeneratorsRejectNonPositive(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name      string
+		generator ValueGenerator
+		want      string
+	}{
+		{name: "password zero", generator: RandomPassword(0), want: "password length must be positive"},
+		{name: "password negative", generator: RandomPassword(-4), want: "password length must be positive"},
+		{name: "token zero", generator: HexToken(0), want: "token size must be positive"},
+		{name: "token negative", generator: HexToken(-1), want: "token size must be positive"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := test.generator(ctx, types.Secret{})
+			if err == nil || err.Error() != test.want {
+				t.Fatalf("generator broke, expecting error %q, getting %v instead", test.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package locker
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/lockerpm/secrets-sdk-go/types"
+)
+
+type InjectOptions struct {
+	// Prefix is prepended to every variable name, after normalization
+	Prefix string
+	// Normalize turns spaces and dashes into "_" and upper-cases the name
+	Normalize bool
+	// Overwrite replaces variables that are already set
+	Overwrite bool
+	// Allow and Deny are glob patterns matched against the secret key, Deny wins over Allow
+	Allow []string
+	Deny  []string
+}
+
+// InjectEnv calls os.Setenv for every secret of env (falling back to ALL, env == nil means ALL only)
+// and returns the names of the variables it set so they can be unset later. Two keys mapping to the same
+// variable name are an error and nothing is set.
+func (locker *Locker) InjectEnv(env *string, opts *InjectOptions) ([]string, error) {
+	if opts == nil {
+		opts = &InjectOptions{}
+	}
+
+	secrets, err := locker.listRunSecrets(env)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(secrets))
+	for key := range secrets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// names are resolved first, a collision must not leave part of the variables set
+	var names []string
+	nameKeys := make(map[string]string, len(keys))
+	for _, key := range keys {
+		if len(opts.Allow) > 0 {
+			allowed, err := matchAnyPattern(opts.Allow, key)
+			if err != nil {
+				return nil, err
+			}
+			if !allowed {
+				continue
+			}
+		}
+
+		denied, err := matchAnyPattern(opts.Deny, key)
+		if err != nil {
+			return nil, err
+		}
+		if denied {
+			continue
+		}
+
+		name := key
+		if opts.Normalize {
+			name = normalizeEnvName(name)
+		}
+		name = opts.Prefix + name
+		if !isValidEnvName(name) {
+			continue
+		}
+
+		if other, ok := nameKeys[name]; ok {
+			types.CURRENT_ERR = types.ERR_INPUT
+			return nil, fmt.Errorf("secrets %s and %s both map to environment variable %s", other, key, name)
+		}
+		nameKeys[name] = key
+		names = append(names, name)
+	}
+
+	var setNames []string
+	for _, name := range names {
+		if _, exists := os.LookupEnv(name); exists && !opts.Overwrite {
+			continue
+		}
+
+		err = os.Setenv(name, secrets[nameKeys[name]].Value)
+		if err != nil {
+			types.CURRENT_ERR = types.ERR_FUNC
+			return setNames, fmt.Errorf("error setting environment variable %s: %w", name, err)
+		}
+		setNames = append(setNames, name)
+	}
+
+	return setNames, nil
+}
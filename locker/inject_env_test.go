@@ -0,0 +1,108 @@
+package locker
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lockerpm/secrets-sdk-go/types"
+)
+
+// unsetOnCleanup removes the variables set by InjectEnv once the test is done
+func unsetOnCleanup(t *testing.T, names []string) {
+	t.Cleanup(func() {
+		for _, name := range names {
+			os.Unsetenv(name)
+		}
+	})
+}
+
+func TestInjectEnv(t *testing.T) {
+	server, client := newTestServer(t)
+	server.addEnvironment(t, "production")
+	server.addSecret(t, "db-password", "all-password", "")
+	server.addSecret(t, "db-password", "production-password", "production")
+	server.addSecret(t, "api token", "token", "")
+	server.addSecret(t, "INTERNAL_KEY", "internal", "")
+	server.addSecret(t, "EXISTING", "secret-value", "")
+
+	t.Setenv("LOCKER_TEST_EXISTING", "os-value")
+
+	prod := "production"
+	tests := []struct {
+		name   string
+		opts   *InjectOptions
+		want   []string
+		values map[string]string
+	}{
+		{
+			name: "normalize and prefix",
+			opts: &InjectOptions{Prefix: "LOCKER_TEST_", Normalize: true},
+			want: []string{"LOCKER_TEST_INTERNAL_KEY", "LOCKER_TEST_API_TOKEN", "LOCKER_TEST_DB_PASSWORD"},
+			values: map[string]string{
+				"LOCKER_TEST_DB_PASSWORD": "production-password",
+				"LOCKER_TEST_API_TOKEN":   "token",
+				"LOCKER_TEST_EXISTING":    "os-value",
+			},
+		},
+		{
+			name:   "overwrite",
+			opts:   &InjectOptions{Prefix: "LOCKER_TEST_", Overwrite: true, Allow: []string{"EXISTING"}},
+			want:   []string{"LOCKER_TEST_EXISTING"},
+			values: map[string]string{"LOCKER_TEST_EXISTING": "secret-value"},
+		},
+		{
+			name:   "allow and deny",
+			opts:   &InjectOptions{Prefix: "LOCKER_TEST_", Allow: []string{"INTERNAL_*", "db-*"}, Deny: []string{"INTERNAL_*"}},
+			want:   []string{"LOCKER_TEST_db-password"},
+			values: map[string]string{"LOCKER_TEST_db-password": "production-password"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			names, err := client.InjectEnv(&prod, test.opts)
+			unsetOnCleanup(t, names)
+			if err != nil {
+				t.Fatalf("inject env broke, error: %v", err)
+			}
+			if !reflect.DeepEqual(names, test.want) {
+				t.Fatalf("inject env broke, expecting %v set, getting %v instead", test.want, names)
+			}
+			for name, value := range test.values {
+				if got := os.Getenv(name); got != value {
+					t.Fatalf("inject env broke, expecting %s=%q, getting %q instead", name, value, got)
+				}
+			}
+		})
+	}
+
+	_, err := client.InjectEnv(&prod, &InjectOptions{Allow: []string{"["}})
+	if err == nil || types.CURRENT_ERR != types.ERR_INPUT {
+		t.Fatalf("inject env broke, expecting an invalid pattern error, getting %v", err)
+	}
+}
+
+func TestInjectEnvCollision(t *testing.T) {
+	server, client := newTestServer(t)
+	server.addSecret(t, "db-url", "postgres://a", "")
+	server.addSecret(t, "DB_URL", "postgres://b", "")
+	server.addSecret(t, "A_FIRST", "first", "")
+
+	names, err := client.InjectEnv(nil, &InjectOptions{Prefix: "LOCKER_TEST_", Normalize: true})
+	unsetOnCleanup(t, names)
+	if err == nil || !strings.Contains(err.Error(), "DB_URL and db-url both map to environment variable LOCKER_TEST_DB_URL") {
+		t.Fatalf("inject env broke, expecting a collision error, getting %v", err)
+	}
+	if len(names) != 0 || os.Getenv("LOCKER_TEST_A_FIRST") != "" {
+		t.Fatalf("inject env broke, nothing must be set on a collision, getting %v", names)
+	}
+
+	// without normalization both names are distinct
+	names, err = client.InjectEnv(nil, &InjectOptions{Prefix: "LOCKER_TEST_", Overwrite: true})
+	unsetOnCleanup(t, names)
+	if err != nil || len(names) != 3 {
+		t.Fatalf("inject env broke, getting %v (%v)", names, err)
+	}
+}
@@ -0,0 +1,87 @@
+package locker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/lockerpm/secrets-sdk-go/types"
+
+	"gorm.io/gorm"
+)
+
+const iterBatchSize = 500
+
+// ErrStopIteration can be returned by an IterSecrets callback to stop early without IterSecrets returning an error
+var ErrStopIteration = errors.New("stop iteration")
+
+// IterSecrets streams the secrets of env (every secret when env == nil, like ListSecret) to fn. Rows are read from
+// the local cache in pages and decrypted one at a time, iteration stops at the first error returned by fn.
+func (locker *Locker) IterSecrets(ctx context.Context, env *string, fn func(types.Secret) error) error {
+	if fn == nil {
+		types.CURRENT_ERR = types.ERR_INPUT
+		return fmt.Errorf("callback must not be nil")
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	err := locker.syncSecrets()
+	if err != nil {
+		return err
+	}
+
+	query := locker.dBConn.Model(&types.Secret{})
+	if env != nil {
+		envHash, err := locker.getHash(*env)
+		if err != nil {
+			return err
+		}
+		query = query.Where("environment_hash = ?", envHash)
+	}
+
+	err = locker.scanSecrets(query, func(secObj types.Secret) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		err := dataDecryption(&secObj, locker.symKey, locker.macKey)
+		if err != nil {
+			return err
+		}
+		locker.rememberValue(secObj.Value)
+
+		return fn(secObj)
+	})
+	if errors.Is(err, ErrStopIteration) {
+		return nil
+	}
+
+	return err
+}
+
+// scanSecrets hands the still encrypted rows selected by query to fn, loading them in batches
+func (locker *Locker) scanSecrets(query *gorm.DB, fn func(types.Secret) error) error {
+	var batch []types.Secret
+	var fnErr error
+	result := query.FindInBatches(&batch, iterBatchSize, func(tx *gorm.DB, _ int) error {
+		for _, secObj := range batch {
+			fnErr = fn(secObj)
+			if fnErr != nil {
+				return fnErr
+			}
+		}
+		return nil
+	})
+
+	if fnErr != nil {
+		return fnErr
+	}
+	if result.Error != nil && !errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		types.CURRENT_ERR = types.ERR_DB
+		return fmt.Errorf("error querying secret: %v", result.Error)
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package locker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/lockerpm/secrets-sdk-go/types"
+)
+
+func TestIterSecrets(t *testing.T) {
+	server, client := newTestServer(t)
+	server.addEnvironment(t, "production")
+	server.addSecret(t, "DB_HOST", "db-all", "")
+	server.addSecret(t, "DB_USER", "app", "")
+	server.addSecret(t, "DB_HOST", "db-production", "production")
+
+	var keys []string
+	err := client.IterSecrets(context.Background(), nil, func(secObj types.Secret) error {
+		keys = append(keys, secObj.Key+"="+secObj.Value)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("iterate secrets broke, error: %v", err)
+	}
+	sort.Strings(keys)
+	if want := []string{"DB_HOST=db-all", "DB_HOST=db-production", "DB_USER=app"}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("iterate secrets broke, expecting %v, getting %v instead", want, keys)
+	}
+
+	prod := "production"
+	keys = nil
+	err = client.IterSecrets(context.Background(), &prod, func(secObj types.Secret) error {
+		keys = append(keys, secObj.Key+"="+secObj.Value)
+		return nil
+	})
+	if err != nil || !reflect.DeepEqual(keys, []string{"DB_HOST=db-production"}) {
+		t.Fatalf("iterate secrets broke for an environment, getting %v (%v)", keys, err)
+	}
+}
+
+func TestIterSecretsStop(t *testing.T) {
+	server, client := newTestServer(t)
+	for i := 0; i < 5; i++ {
+		server.addSecret(t, fmt.Sprintf("KEY_%d", i), "value", "")
+	}
+
+	calls := 0
+	err := client.IterSecrets(context.Background(), nil, func(types.Secret) error {
+		calls++
+		if calls == 2 {
+			return ErrStopIteration
+		}
+		return nil
+	})
+	if err != nil || calls != 2 {
+		t.Fatalf("iterate secrets broke, expecting to stop after 2 secrets without error, getting %d (%v)", calls, err)
+	}
+
+	errCallback := errors.New("callback failed")
+	calls = 0
+	err = client.IterSecrets(context.Background(), nil, func(types.Secret) error {
+		calls++
+		return errCallback
+	})
+	if !errors.Is(err, errCallback) || calls != 1 {
+		t.Fatalf("iterate secrets broke, expecting the callback's error after 1 secret, getting %d (%v)", calls, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	calls = 0
+	err = client.IterSecrets(ctx, nil, func(types.Secret) error {
+		calls++
+		cancel()
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) || calls != 1 {
+		t.Fatalf("iterate secrets broke, expecting to stop on cancellation, getting %d (%v)", calls, err)
+	}
+
+	err = client.IterSecrets(context.Background(), nil, nil)
+	if err == nil || types.CURRENT_ERR != types.ERR_INPUT {
+		t.Fatalf("iterate secrets broke, expecting an error for a nil callback, getting %v", err)
+	}
+}
+
+func TestScanSecretsBatches(t *testing.T) {
+	_, client := newTestServer(t)
+	err := client.syncSecrets()
+	if err != nil {
+		t.Fatalf("sync broke, error: %v", err)
+	}
+
+	rows := make([]types.Secret, iterBatchSize*2+1)
+	for i := range rows {
+		rows[i] = types.Secret{ID: fmt.Sprintf("sec-%04d", i), SecretHash: fmt.Sprintf("hash-%04d", i)}
+	}
+	result := client.dBConn.CreateInBatches(&rows, 200)
+	if result.Error != nil {
+		t.Fatalf("caching secrets broke, error: %v", result.Error)
+	}
+
+	seen := make(map[string]bool)
+	err = client.scanSecrets(client.dBConn.Model(&types.Secret{}), func(secObj types.Secret) error {
+		seen[secObj.ID] = true
+		return nil
+	})
+	if err != nil || len(seen) != len(rows) {
+		t.Fatalf("scan secrets broke, expecting %d rows, getting %d (%v)", len(rows), len(seen), err)
+	}
+}
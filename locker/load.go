@@ -0,0 +1,316 @@
+package locker
+
+import (
+	"context"
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lockerpm/secrets-sdk-go/types"
+)
+
+// LoadError aggregates every problem found while binding secrets to a struct
+type LoadError struct {
+	Missing []string
+	Invalid []error
+}
+
+func (e *LoadError) Error() string {
+	var parts []string
+	if len(e.Missing) > 0 {
+		parts = append(parts, fmt.Sprintf("missing required secrets: %s", strings.Join(e.Missing, ", ")))
+	}
+	for _, err := range e.Invalid {
+		parts = append(parts, err.Error())
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (e *LoadError) Unwrap() []error {
+	return e.Invalid
+}
+
+type loadTag struct {
+	key      string
+	env      string
+	required bool
+	def      *string
+}
+
+type secretLoader struct {
+	locker  *Locker
+	secrets []types.Secret
+	views   map[string]map[string]types.Secret
+	loadErr LoadError
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Load populates cfg (a pointer to struct) from secrets, using `locker` struct tags such as
+// `locker:"DB_PASSWORD,env=production,required"` or `locker:"TIMEOUT,default=5s"`.
+// All fields are served by a single ListSecret call.
+func (locker *Locker) Load(ctx context.Context, cfg interface{}) error {
+	value := reflect.ValueOf(cfg)
+	if value.Kind() != reflect.Pointer || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		types.CURRENT_ERR = types.ERR_INPUT
+		return fmt.Errorf("load target must be a non-nil pointer to struct")
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	secObjs, err := locker.ListSecret(nil)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	loader := secretLoader{
+		locker:  locker,
+		secrets: secObjs,
+		views:   make(map[string]map[string]types.Secret),
+	}
+
+	err = loader.loadStruct(value.Elem(), "", "")
+	if err != nil {
+		return err
+	}
+
+	if len(loader.loadErr.Missing) > 0 || len(loader.loadErr.Invalid) > 0 {
+		if len(loader.loadErr.Missing) > 0 {
+			types.CURRENT_ERR = types.ERR_NOT_FOUND
+		} else {
+			types.CURRENT_ERR = types.ERR_INPUT
+		}
+		return &loader.loadErr
+	}
+
+	return nil
+}
+
+func (loader *secretLoader) view(env string) (map[string]types.Secret, error) {
+	if resolved, ok := loader.views[env]; ok {
+		return resolved, nil
+	}
+
+	var envPtr *string
+	if env != "" {
+		envPtr = &env
+	}
+
+	resolved, err := loader.locker.resolveSecrets(loader.secrets, envPtr)
+	if err != nil {
+		return nil, err
+	}
+	loader.views[env] = resolved
+
+	return resolved, nil
+}
+
+func (loader *secretLoader) loadStruct(value reflect.Value, path, env string) error {
+	valueType := value.Type()
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		fieldType := valueType.Field(i)
+		if !field.CanSet() {
+			continue
+		}
+
+		rawTag, hasTag := fieldType.Tag.Lookup("locker")
+		if rawTag == "-" {
+			continue
+		}
+
+		fieldPath := fieldType.Name
+		if path != "" {
+			fieldPath = path + "." + fieldType.Name
+		}
+
+		tag, err := parseLoadTag(rawTag)
+		if err != nil {
+			loader.loadErr.Invalid = append(loader.loadErr.Invalid, fmt.Errorf("field %s: %w", fieldPath, err))
+			continue
+		}
+		if tag.env == "" {
+			tag.env = env
+		}
+
+		// nested structs inherit the parent's environment unless they set their own
+		if tag.key == "" && isNestedStruct(fieldType.Type) {
+			if field.Kind() == reflect.Pointer {
+				if field.IsNil() {
+					// only allocate optional sections that opt in with a tag
+					if !hasTag {
+						continue
+					}
+					field.Set(reflect.New(fieldType.Type.Elem()))
+				}
+				field = field.Elem()
+			}
+			err = loader.loadStruct(field, fieldPath, tag.env)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !hasTag || tag.key == "" {
+			continue
+		}
+
+		resolved, err := loader.view(tag.env)
+		if err != nil {
+			return err
+		}
+
+		var raw string
+		if secObj, ok := resolved[tag.key]; ok {
+			raw = secObj.Value
+		} else if tag.def != nil {
+			raw = *tag.def
+		} else {
+			if tag.required {
+				missing := tag.key
+				if tag.env != "" {
+					missing = fmt.Sprintf("%s (env %s)", tag.key, tag.env)
+				}
+				loader.loadErr.Missing = append(loader.loadErr.Missing, missing)
+			}
+			continue
+		}
+
+		err = setFieldFromString(field, raw)
+		if err != nil {
+			loader.loadErr.Invalid = append(loader.loadErr.Invalid, fmt.Errorf("field %s (%s): %w", fieldPath, tag.key, err))
+		}
+	}
+
+	return nil
+}
+
+// parseLoadTag splits "KEY,env=...,required,default=..."; a default value may itself contain commas
+// as long as it is the last option
+func parseLoadTag(rawTag string) (loadTag, error) {
+	var tag loadTag
+	if rawTag == "" {
+		return tag, nil
+	}
+
+	parts := strings.Split(rawTag, ",")
+	tag.key = strings.TrimSpace(parts[0])
+
+	inDefault := false
+	for _, part := range parts[1:] {
+		switch {
+		case inDefault:
+			*tag.def += "," + part
+		case strings.TrimSpace(part) == "required":
+			tag.required = true
+		case strings.HasPrefix(part, "env="):
+			tag.env = strings.TrimPrefix(part, "env=")
+		case strings.HasPrefix(part, "default="):
+			def := strings.TrimPrefix(part, "default=")
+			tag.def = &def
+			inDefault = true
+		default:
+			return loadTag{}, fmt.Errorf("unknown tag option %q", part)
+		}
+	}
+
+	return tag, nil
+}
+
+func isNestedStruct(fieldType reflect.Type) bool {
+	if fieldType.Kind() == reflect.Pointer {
+		fieldType = fieldType.Elem()
+	}
+	if fieldType.Kind() != reflect.Struct {
+		return false
+	}
+	return !reflect.PointerTo(fieldType).Implements(textUnmarshalerType)
+}
+
+func setFieldFromString(field reflect.Value, raw string) error {
+	if field.Kind() == reflect.Pointer {
+		elem := reflect.New(field.Type().Elem())
+		err := setFieldFromString(elem.Elem(), raw)
+		if err != nil {
+			return err
+		}
+		field.Set(elem)
+		return nil
+	}
+
+	if field.CanAddr() && field.Addr().Type().Implements(textUnmarshalerType) {
+		return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(raw))
+	}
+
+	if field.Type() == durationType {
+		duration, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(duration))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		field.SetBool(parsed)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(raw, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(raw, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(raw, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(parsed)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() == reflect.Uint8 {
+			field.SetBytes([]byte(raw))
+			return nil
+		}
+
+		var items []string
+		if strings.TrimSpace(raw) != "" {
+			items = strings.Split(raw, ",")
+		}
+		slice := reflect.MakeSlice(field.Type(), len(items), len(items))
+		for i, item := range items {
+			err := setFieldFromString(slice.Index(i), strings.TrimSpace(item))
+			if err != nil {
+				return fmt.Errorf("item %d: %w", i, err)
+			}
+		}
+		field.Set(slice)
+	default:
+		return errors.New("unsupported field type " + field.Type().String())
+	}
+
+	return nil
+}
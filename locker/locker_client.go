@@ -42,6 +42,8 @@ type Locker struct {
 	Export           bool
 	Unsafe           bool
 	GettingFromLocal bool
+	// DryRun records the create, update and delete calls (see DryRunPlan) instead of sending them
+	DryRun bool
 
 	dBConn           *gorm.DB
 	hash             string
@@ -49,6 +51,11 @@ type Locker struct {
 	symKey           []byte
 	macKey           []byte
 	currentOperation string
+	validationRules  []validationEntry
+	redactor         *Redactor
+	dryRunPlan       []PlannedCall
+	auditSink        AuditSink
+	auditActor       map[string]string
 }
 
 func (locker *Locker) NewLockerClient() {
@@ -262,7 +262,7 @@ func (locker *Locker) fetchRevisionDate(kind string) (types.RevisionDate, bool,
 	}
 
 	switch kind {
-	case types.FETCH_KIND_SEC:
+	case types.FETCH_KIND_SEC, types.FETCH_KIND_RUN:
 		lastCall = localRevDate.LastCallSec
 	case types.FETCH_KIND_ENV:
 		lastCall = localRevDate.LastCallEnv
@@ -289,7 +289,7 @@ func (locker *Locker) fetchRevisionDate(kind string) (types.RevisionDate, bool,
 
 	localRevDate.RevisionDate = fetchedRevDate
 	switch kind {
-	case types.FETCH_KIND_SEC:
+	case types.FETCH_KIND_SEC, types.FETCH_KIND_RUN:
 		localRevDate.LastCallSec = float64(time.Now().Unix())
 	case types.FETCH_KIND_ENV:
 		localRevDate.LastCallEnv = float64(time.Now().Unix())
@@ -8,6 +8,8 @@ import (
 	"time"
 
 	"github.com/lockerpm/secrets-sdk-go/types"
+
+	"gorm.io/gorm"
 )
 
 func (locker *Locker) httpActionOut(method, endpoint string, body []byte) ([]byte, int, error) {
@@ -18,6 +20,8 @@ func (locker *Locker) httpActionOut(method, endpoint string, body []byte) ([]byt
 		req, err = http.NewRequest(http.MethodPost, endpoint, bytes.NewBuffer(body))
 	case "PUT":
 		req, err = http.NewRequest(http.MethodPut, endpoint, bytes.NewBuffer(body))
+	case "DELETE":
+		req, err = http.NewRequest(http.MethodDelete, endpoint, bytes.NewBuffer(body))
 	default:
 		req, err = http.NewRequest(http.MethodGet, endpoint, bytes.NewBuffer(body))
 	}
@@ -50,7 +54,7 @@ func (locker *Locker) httpActionOut(method, endpoint string, body []byte) ([]byt
 		return nil, statusCode, fmt.Errorf("error reading response: %w", err)
 	}
 
-	if statusCode != 201 && statusCode != 200 {
+	if statusCode != 201 && statusCode != 200 && statusCode != 204 {
 		srvMsg, err := unmarshalAny[types.ServerErrorMsg](resBody)
 		if err != nil {
 			return nil, statusCode, err
@@ -69,15 +73,7 @@ func (locker *Locker) httpActionOut(method, endpoint string, body []byte) ([]byt
 }
 
 func editItem[Struct any](locker *Locker, kind, ID string, body []byte) (*Struct, error) {
-	var dataEndpoint string
-	switch kind {
-	case types.FETCH_KIND_SEC:
-		dataEndpoint = fmt.Sprintf("%s/v1/secrets/%s", locker.APIBase, ID)
-	case types.FETCH_KIND_ENV:
-		dataEndpoint = fmt.Sprintf("%s/v1/environments/%s", locker.APIBase, ID)
-	}
-
-	resBody, code, err := locker.httpActionOut("PUT", dataEndpoint, body)
+	resBody, code, err := locker.httpActionOut("PUT", locker.itemEndpoint(kind, ID), body)
 	if err != nil {
 		return nil, err
 	}
@@ -108,15 +104,7 @@ func editItem[Struct any](locker *Locker, kind, ID string, body []byte) (*Struct
 }
 
 func createItem[Struct any](locker *Locker, kind string, body []byte) (*Struct, error) {
-	var dataEndpoint string
-	switch kind {
-	case types.FETCH_KIND_SEC:
-		dataEndpoint = fmt.Sprintf("%s/v1/secrets", locker.APIBase)
-	case types.FETCH_KIND_ENV:
-		dataEndpoint = fmt.Sprintf("%s/v1/environments", locker.APIBase)
-	}
-
-	resBody, _, err := locker.httpActionOut("POST", dataEndpoint, body)
+	resBody, _, err := locker.httpActionOut("POST", locker.itemEndpoint(kind, ""), body)
 	if err != nil {
 		return nil, err
 	}
@@ -128,3 +116,45 @@ func createItem[Struct any](locker *Locker, kind string, body []byte) (*Struct,
 
 	return encRes, nil
 }
+
+// deleteItem deletes item ID of kind on the server, then from the local cache. Every failure is returned,
+// including a 404: the cache is left untouched so a wrong ID or endpoint never looks like a deletion.
+func (locker *Locker) deleteItem(kind, ID string) error {
+	_, code, err := locker.httpActionOut("DELETE", locker.itemEndpoint(kind, ID), nil)
+	if err != nil {
+		if code == 404 {
+			types.CURRENT_ERR = types.ERR_NOT_FOUND
+		}
+		return err
+	}
+
+	var result *gorm.DB
+	switch kind {
+	case types.FETCH_KIND_SEC:
+		result = locker.dBConn.Where("id = ?", ID).Delete(&types.Secret{})
+	case types.FETCH_KIND_ENV:
+		result = locker.dBConn.Where("id = ?", ID).Delete(&types.Environment{})
+	}
+	if result != nil && result.Error != nil {
+		types.CURRENT_ERR = types.ERR_DB
+		return fmt.Errorf("error deleting cached item: %w", result.Error)
+	}
+
+	return nil
+}
+
+// itemEndpoint returns the endpoint of the item ID of kind, or of the collection when ID is empty
+func (locker *Locker) itemEndpoint(kind, ID string) string {
+	var dataEndpoint string
+	switch kind {
+	case types.FETCH_KIND_SEC:
+		dataEndpoint = fmt.Sprintf("%s/v1/secrets", locker.APIBase)
+	case types.FETCH_KIND_ENV:
+		dataEndpoint = fmt.Sprintf("%s/v1/environments", locker.APIBase)
+	}
+
+	if ID != "" {
+		dataEndpoint += "/" + ID
+	}
+	return dataEndpoint
+}
@@ -111,6 +111,9 @@ func (locker *Locker) prepareKey() ([]byte, []byte, error) {
 
 func (locker *Locker) prepare(input, dataType string) error {
 	var err error
+	// emptyFetch only describes the fetches of the current call
+	locker.emptyFetch = false
+
 	locker.hash, err = locker.prepareHash(input)
 	if err != nil {
 		return err
@@ -0,0 +1,258 @@
+package locker
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lockerpm/secrets-sdk-go/types"
+
+	"gorm.io/gorm"
+)
+
+type SecretFilter struct {
+	// key filters, all the ones set must match
+	Prefix string
+	Glob   string
+	Regex  string
+	// DescriptionContains is matched case-insensitively
+	DescriptionContains string
+
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	RevisedAfter  time.Time
+	RevisedBefore time.Time
+
+	// Scope is types.SCOPE_ANY (default), types.SCOPE_ALL (secrets of ALL only)
+	// or types.SCOPE_ENV (environment-scoped secrets only)
+	Scope string
+	// Env restricts environment-scoped secrets to a single environment, with types.SCOPE_ANY a key defined
+	// in both ALL and Env is returned once, with the environment's value
+	Env *string
+
+	// SortBy is types.SORT_KEY (default), types.SORT_CREATION_DATE or types.SORT_REVISION_DATE
+	SortBy     string
+	Descending bool
+	// Limit of 0 means no limit
+	Limit  int
+	Offset int
+}
+
+type secretMatcher struct {
+	filter SecretFilter
+	regex  *regexp.Regexp
+}
+
+// QuerySecrets lists the secrets matching filter. Scope and dates are filtered by the local database, key and
+// description filters run on decrypted data, and values are only decrypted for the returned page.
+func (locker *Locker) QuerySecrets(filter SecretFilter) ([]types.Secret, error) {
+	matcher, err := newSecretMatcher(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	err = locker.syncSecrets()
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := locker.filteredSecretQuery(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	type candidate struct {
+		secObj types.Secret
+		key    string
+	}
+
+	var candidates []candidate
+	err = locker.scanSecrets(query, func(secObj types.Secret) error {
+		key, ok, err := locker.matchSecret(matcher, secObj)
+		if err != nil {
+			return err
+		}
+		if ok {
+			candidates = append(candidates, candidate{secObj: secObj, key: key})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// with an environment, its own value hides the ALL one of the same key, like resolveSecrets
+	if filter.Env != nil && (filter.Scope == "" || filter.Scope == types.SCOPE_ANY) {
+		positions := make(map[string]int, len(candidates))
+		deduped := candidates[:0]
+		for _, candidate := range candidates {
+			i, ok := positions[candidate.key]
+			if !ok {
+				positions[candidate.key] = len(deduped)
+				deduped = append(deduped, candidate)
+				continue
+			}
+			if candidate.secObj.EnvironmentHash != nil {
+				deduped[i] = candidate
+			}
+		}
+		candidates = deduped
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if filter.Descending {
+			a, b = b, a
+		}
+		switch filter.SortBy {
+		case types.SORT_CREATION_DATE:
+			return a.secObj.CreationDate < b.secObj.CreationDate
+		case types.SORT_REVISION_DATE:
+			return a.secObj.RevisionDate < b.secObj.RevisionDate
+		default:
+			return a.key < b.key
+		}
+	})
+
+	start, end := pageBounds(len(candidates), filter.Offset, filter.Limit)
+	page := make([]types.Secret, 0, end-start)
+	for _, candidate := range candidates[start:end] {
+		secObj := candidate.secObj
+		err = dataDecryption(&secObj, locker.symKey, locker.macKey)
+		if err != nil {
+			return nil, err
+		}
+		locker.rememberValue(secObj.Value)
+		page = append(page, secObj)
+	}
+
+	return page, nil
+}
+
+func newSecretMatcher(filter SecretFilter) (*secretMatcher, error) {
+	switch filter.Scope {
+	case "", types.SCOPE_ANY, types.SCOPE_ALL, types.SCOPE_ENV:
+	default:
+		types.CURRENT_ERR = types.ERR_INPUT
+		return nil, fmt.Errorf("invalid scope %q", filter.Scope)
+	}
+
+	switch filter.SortBy {
+	case "", types.SORT_KEY, types.SORT_CREATION_DATE, types.SORT_REVISION_DATE:
+	default:
+		types.CURRENT_ERR = types.ERR_INPUT
+		return nil, fmt.Errorf("invalid sort field %q", filter.SortBy)
+	}
+
+	if filter.Limit < 0 || filter.Offset < 0 {
+		types.CURRENT_ERR = types.ERR_INPUT
+		return nil, fmt.Errorf("limit and offset must not be negative")
+	}
+
+	if filter.Glob != "" {
+		_, err := path.Match(filter.Glob, "")
+		if err != nil {
+			types.CURRENT_ERR = types.ERR_INPUT
+			return nil, fmt.Errorf("invalid glob %q: %w", filter.Glob, err)
+		}
+	}
+
+	matcher := secretMatcher{filter: filter}
+	if filter.Regex != "" {
+		var err error
+		matcher.regex, err = regexp.Compile(filter.Regex)
+		if err != nil {
+			types.CURRENT_ERR = types.ERR_INPUT
+			return nil, fmt.Errorf("invalid regex %q: %w", filter.Regex, err)
+		}
+	}
+
+	return &matcher, nil
+}
+
+// filteredSecretQuery applies the filters that work on plain columns
+func (locker *Locker) filteredSecretQuery(filter SecretFilter) (*gorm.DB, error) {
+	query := locker.dBConn.Model(&types.Secret{})
+
+	switch filter.Scope {
+	case types.SCOPE_ALL:
+		query = query.Where("environment_hash is NULL")
+	case types.SCOPE_ENV:
+		query = query.Where("environment_hash is not NULL")
+	}
+
+	if filter.Env != nil && filter.Scope != types.SCOPE_ALL {
+		envHash, err := locker.getHash(*filter.Env)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("(environment_hash = ? OR environment_hash is NULL)", envHash)
+	}
+
+	if !filter.CreatedAfter.IsZero() {
+		query = query.Where("creation_date >= ?", unixSeconds(filter.CreatedAfter))
+	}
+	if !filter.CreatedBefore.IsZero() {
+		query = query.Where("creation_date < ?", unixSeconds(filter.CreatedBefore))
+	}
+	if !filter.RevisedAfter.IsZero() {
+		query = query.Where("revision_date >= ?", unixSeconds(filter.RevisedAfter))
+	}
+	if !filter.RevisedBefore.IsZero() {
+		query = query.Where("revision_date < ?", unixSeconds(filter.RevisedBefore))
+	}
+
+	return query, nil
+}
+
+// matchSecret decrypts only what the filters need and returns the decrypted key
+func (locker *Locker) matchSecret(matcher *secretMatcher, secObj types.Secret) (string, bool, error) {
+	key, err := decryptString(secObj.Key, locker.symKey, locker.macKey)
+	if err != nil {
+		return "", false, err
+	}
+
+	filter := matcher.filter
+	if filter.Prefix != "" && !strings.HasPrefix(key, filter.Prefix) {
+		return key, false, nil
+	}
+	if filter.Glob != "" {
+		matched, _ := path.Match(filter.Glob, key)
+		if !matched {
+			return key, false, nil
+		}
+	}
+	if matcher.regex != nil && !matcher.regex.MatchString(key) {
+		return key, false, nil
+	}
+
+	if filter.DescriptionContains != "" {
+		desc, err := decryptString(secObj.Description, locker.symKey, locker.macKey)
+		if err != nil {
+			return "", false, err
+		}
+		if !strings.Contains(strings.ToLower(desc), strings.ToLower(filter.DescriptionContains)) {
+			return key, false, nil
+		}
+	}
+
+	return key, true, nil
+}
+
+func pageBounds(total, offset, limit int) (int, int) {
+	if offset > total {
+		offset = total
+	}
+	end := total
+	if limit > 0 && offset+limit < total {
+		end = offset + limit
+	}
+	return offset, end
+}
+
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Second)
+}
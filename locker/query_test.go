@@ -0,0 +1,171 @@
+package locker
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lockerpm/secrets-sdk-go/types"
+)
+
+// scopedKeys returns KEY@environment for each secret, ALL for the shared ones
+func scopedKeys(secObjs []types.Secret) []string {
+	keys := make([]string, 0, len(secObjs))
+	for _, secObj := range secObjs {
+		env := "ALL"
+		if secObj.EnvironmentName != nil {
+			env = *secObj.EnvironmentName
+		}
+		keys = append(keys, secObj.Key+"@"+env)
+	}
+	return keys
+}
+
+func TestQuerySecrets(t *testing.T) {
+	server, client := newTestServer(t)
+	server.addEnvironment(t, "staging")
+	server.addEnvironment(t, "production")
+	server.addSecret(t, "DB_HOST", "db-all", "")
+	server.addSecret(t, "DB_USER", "app", "")
+	apiKey := server.addSecret(t, "API_KEY", "key", "")
+	server.describeSecret(t, apiKey.ID, "Payment PROVIDER key")
+	prodHost := server.addSecret(t, "DB_HOST", "db-production", "production")
+	server.addSecret(t, "DB_PASSWORD", "s3cr3t", "production")
+	server.addSecret(t, "DB_HOST", "db-staging", "staging")
+
+	prod := "production"
+	revised := time.Unix(int64(prodHost.RevisionDate), 0)
+
+	tests := []struct {
+		name   string
+		filter SecretFilter
+		want   []string
+	}{
+		{
+			name:   "everything",
+			filter: SecretFilter{},
+			want:   []string{"API_KEY@ALL", "DB_HOST@ALL", "DB_HOST@production", "DB_HOST@staging", "DB_PASSWORD@production", "DB_USER@ALL"},
+		},
+		{
+			name:   "prefix",
+			filter: SecretFilter{Prefix: "DB_P"},
+			want:   []string{"DB_PASSWORD@production"},
+		},
+		{
+			name:   "glob",
+			filter: SecretFilter{Glob: "*_USER"},
+			want:   []string{"DB_USER@ALL"},
+		},
+		{
+			name:   "regex",
+			filter: SecretFilter{Regex: "^(API|DB)_(KEY|USER)$"},
+			want:   []string{"API_KEY@ALL", "DB_USER@ALL"},
+		},
+		{
+			name:   "description",
+			filter: SecretFilter{DescriptionContains: "provider"},
+			want:   []string{"API_KEY@ALL"},
+		},
+		{
+			name:   "ALL scope",
+			filter: SecretFilter{Scope: types.SCOPE_ALL, Env: &prod},
+			want:   []string{"API_KEY@ALL", "DB_HOST@ALL", "DB_USER@ALL"},
+		},
+		{
+			name:   "environment scope",
+			filter: SecretFilter{Scope: types.SCOPE_ENV},
+			want:   []string{"DB_HOST@production", "DB_HOST@staging", "DB_PASSWORD@production"},
+		},
+		{
+			name:   "environment scope of one environment",
+			filter: SecretFilter{Scope: types.SCOPE_ENV, Env: &prod},
+			want:   []string{"DB_HOST@production", "DB_PASSWORD@production"},
+		},
+		{
+			name:   "any scope of one environment",
+			filter: SecretFilter{Env: &prod},
+			want:   []string{"API_KEY@ALL", "DB_HOST@production", "DB_PASSWORD@production", "DB_USER@ALL"},
+		},
+		{
+			name:   "revised after",
+			filter: SecretFilter{RevisedAfter: revised},
+			want:   []string{"DB_HOST@production", "DB_HOST@staging", "DB_PASSWORD@production"},
+		},
+		{
+			name:   "revised before",
+			filter: SecretFilter{RevisedBefore: revised, Prefix: "DB_"},
+			want:   []string{"DB_HOST@ALL", "DB_USER@ALL"},
+		},
+		{
+			name:   "descending creation date",
+			filter: SecretFilter{SortBy: types.SORT_CREATION_DATE, Descending: true, Prefix: "DB_H"},
+			want:   []string{"DB_HOST@staging", "DB_HOST@production", "DB_HOST@ALL"},
+		},
+		{
+			name:   "page",
+			filter: SecretFilter{Env: &prod, Limit: 2, Offset: 1},
+			want:   []string{"DB_HOST@production", "DB_PASSWORD@production"},
+		},
+		{
+			name:   "page past the end",
+			filter: SecretFilter{Limit: 2, Offset: 10},
+			want:   []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			secObjs, err := client.QuerySecrets(test.filter)
+			if err != nil {
+				t.Fatalf("query secrets broke, error: %v", err)
+			}
+			if keys := scopedKeys(secObjs); !reflect.DeepEqual(keys, test.want) {
+				t.Fatalf("query secrets broke, expecting %v, getting %v instead", test.want, keys)
+			}
+		})
+	}
+
+	secObjs, err := client.QuerySecrets(SecretFilter{Env: &prod, Prefix: "DB_HOST"})
+	if err != nil || len(secObjs) != 1 || secObjs[0].Value != "db-production" {
+		t.Fatalf("query secrets broke, expecting the production value, getting %+v (%v)", secObjs, err)
+	}
+}
+
+func TestQuerySecretsInvalidFilter(t *testing.T) {
+	for _, filter := range []SecretFilter{
+		{Scope: "project"},
+		{SortBy: "value"},
+		{Limit: -1},
+		{Glob: "["},
+		{Regex: "("},
+	} {
+		_, err := (&Locker{}).QuerySecrets(filter)
+		if err == nil || types.CURRENT_ERR != types.ERR_INPUT {
+			t.Fatalf("query secrets broke, expecting an input error for %+v, getting %v", filter, err)
+		}
+	}
+}
+
+func TestQuerySecretsDeletedOnServer(t *testing.T) {
+	server, client := newTestServer(t)
+	host := server.addSecret(t, "DB_HOST", "db1", "")
+	user := server.addSecret(t, "DB_USER", "app", "")
+
+	secObjs, err := client.QuerySecrets(SecretFilter{})
+	if err != nil || len(secObjs) != 2 {
+		t.Fatalf("query secrets broke, getting %+v (%v)", secObjs, err)
+	}
+
+	server.removeSecret(host.ID)
+	secObjs, err = client.QuerySecrets(SecretFilter{})
+	if err != nil || !reflect.DeepEqual(scopedKeys(secObjs), []string{"DB_USER@ALL"}) {
+		t.Fatalf("query secrets broke, the deleted secret must be gone, getting %v (%v)", scopedKeys(secObjs), err)
+	}
+
+	// the last secret is gone, the fetch is empty
+	server.removeSecret(user.ID)
+	secObjs, err = client.QuerySecrets(SecretFilter{})
+	if err != nil || len(secObjs) != 0 {
+		t.Fatalf("query secrets broke, expecting no secret, getting %v (%v)", scopedKeys(secObjs), err)
+	}
+}
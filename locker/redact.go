@@ -0,0 +1,436 @@
+package locker
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"sync"
+)
+
+const defaultRedactionMask = "***"
+const defaultRedactionMinLength = 4
+
+type RedactorOptions struct {
+	// Mask replaces the values, defaults to "***"
+	Mask string
+	// Fingerprint replaces the values with "***<fingerprint>" so occurrences of the same value can be correlated,
+	// fingerprints are keyed per redactor and cannot be matched against a guessed value
+	Fingerprint bool
+	// MinLength ignores shorter values, which would mask common words and numbers, defaults to 4
+	MinLength int
+}
+
+// Redactor masks known secret values in text. The client keeps its redactor populated with every value it decrypts,
+// see Locker.Redactor. Matching uses Aho-Corasick, so the cost does not grow with the number of values.
+type Redactor struct {
+	mask           string
+	fingerprint    bool
+	fingerprintKey []byte
+	minLength      int
+
+	mutex   sync.RWMutex
+	values  map[string]struct{}
+	matcher *ahoCorasick
+}
+
+func NewRedactor(opts *RedactorOptions) *Redactor {
+	if opts == nil {
+		opts = &RedactorOptions{}
+	}
+
+	redactor := &Redactor{
+		mask:        opts.Mask,
+		fingerprint: opts.Fingerprint,
+		minLength:   opts.MinLength,
+		values:      make(map[string]struct{}),
+	}
+	if redactor.mask == "" {
+		redactor.mask = defaultRedactionMask
+	}
+	if redactor.minLength <= 0 {
+		redactor.minLength = defaultRedactionMinLength
+	}
+	if redactor.fingerprint {
+		redactor.fingerprintKey = make([]byte, 32)
+		_, _ = rand.Read(redactor.fingerprintKey)
+	}
+
+	return redactor
+}
+
+// Redactor returns the redactor populated by the client, created on first use
+func (locker *Locker) Redactor() *Redactor {
+	if locker.redactor == nil {
+		locker.redactor = NewRedactor(nil)
+	}
+	return locker.redactor
+}
+
+// SetRedactor replaces the redactor populated by the client, e.g. to change its options
+func (locker *Locker) SetRedactor(redactor *Redactor) {
+	locker.redactor = redactor
+}
+
+// rememberValue registers a decrypted value with the client's redactor
+func (locker *Locker) rememberValue(value string) {
+	locker.Redactor().Add(value)
+}
+
+// Add registers values to mask
+func (redactor *Redactor) Add(values ...string) {
+	redactor.mutex.Lock()
+	defer redactor.mutex.Unlock()
+
+	for _, value := range values {
+		if len(value) < redactor.minLength {
+			continue
+		}
+		if _, ok := redactor.values[value]; ok {
+			continue
+		}
+		redactor.values[value] = struct{}{}
+		// rebuilt on next use
+		redactor.matcher = nil
+	}
+}
+
+// Len returns the number of registered values
+func (redactor *Redactor) Len() int {
+	redactor.mutex.RLock()
+	defer redactor.mutex.RUnlock()
+	return len(redactor.values)
+}
+
+// Redact returns input with every registered value masked
+func (redactor *Redactor) Redact(input string) string {
+	return string(redactor.RedactBytes([]byte(input)))
+}
+
+// RedactBytes returns input with every registered value masked, input is returned as-is when nothing matches
+func (redactor *Redactor) RedactBytes(input []byte) []byte {
+	matcher := redactor.getMatcher()
+	if matcher == nil {
+		return input
+	}
+
+	output, _ := redactor.redactUntil(matcher, input, true)
+	return output
+}
+
+func (redactor *Redactor) getMatcher() *ahoCorasick {
+	redactor.mutex.RLock()
+	matcher := redactor.matcher
+	count := len(redactor.values)
+	redactor.mutex.RUnlock()
+	if matcher != nil || count == 0 {
+		return matcher
+	}
+
+	redactor.mutex.Lock()
+	defer redactor.mutex.Unlock()
+	if redactor.matcher == nil {
+		patterns := make([]string, 0, len(redactor.values))
+		for value := range redactor.values {
+			patterns = append(patterns, value)
+		}
+		redactor.matcher = newAhoCorasick(patterns)
+	}
+	return redactor.matcher
+}
+
+// redactUntil masks the matches of input. Unless final, the end of input that could be the beginning of a value
+// is not processed and its length is returned, so the caller can retry it with more data.
+func (redactor *Redactor) redactUntil(matcher *ahoCorasick, input []byte, final bool) ([]byte, int) {
+	intervals, depth := matcher.findAll(input)
+
+	cut := len(input)
+	if !final {
+		// a value continuing after input must start in the longest suffix of input that begins a value
+		cut = len(input) - depth
+		for _, interval := range intervals {
+			if interval[0] < cut && interval[1] > cut {
+				cut = interval[0]
+				break
+			}
+		}
+	}
+
+	output := make([]byte, 0, cut)
+	pos := 0
+	for _, interval := range intervals {
+		if interval[1] > cut {
+			break
+		}
+		output = append(output, input[pos:interval[0]]...)
+		output = append(output, redactor.replacement(input[interval[0]:interval[1]])...)
+		pos = interval[1]
+	}
+	output = append(output, input[pos:cut]...)
+
+	return output, len(input) - cut
+}
+
+func (redactor *Redactor) replacement(match []byte) string {
+	if !redactor.fingerprint {
+		return redactor.mask
+	}
+
+	mac := hmac.New(sha256.New, redactor.fingerprintKey)
+	mac.Write(match)
+	return redactor.mask + hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// RedactingWriter masks registered values in everything written to it, including values split across writes.
+// The end of the data that could be the beginning of a value is held back until the next Write, Flush or Close.
+type RedactingWriter struct {
+	redactor *Redactor
+	w        io.Writer
+
+	mutex   sync.Mutex
+	pending []byte
+}
+
+// Writer wraps w
+func (redactor *Redactor) Writer(w io.Writer) *RedactingWriter {
+	return &RedactingWriter{redactor: redactor, w: w}
+}
+
+func (writer *RedactingWriter) Write(data []byte) (int, error) {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	writer.pending = append(writer.pending, data...)
+
+	matcher := writer.redactor.getMatcher()
+	if matcher == nil {
+		err := writer.writePending(writer.pending)
+		if err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+
+	output, held := writer.redactor.redactUntil(matcher, writer.pending, false)
+	_, err := writer.w.Write(output)
+	if err != nil {
+		return 0, err
+	}
+	writer.pending = append(writer.pending[:0], writer.pending[len(writer.pending)-held:]...)
+
+	return len(data), nil
+}
+
+// Flush writes the held back data, a value split by a later Write is no longer detected
+func (writer *RedactingWriter) Flush() error {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	return writer.writePending(writer.redactor.RedactBytes(writer.pending))
+}
+
+// Close flushes and closes the underlying writer when it is an io.Closer
+func (writer *RedactingWriter) Close() error {
+	err := writer.Flush()
+	if err != nil {
+		return err
+	}
+
+	if closer, ok := writer.w.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (writer *RedactingWriter) writePending(output []byte) error {
+	if len(output) == 0 {
+		writer.pending = writer.pending[:0]
+		return nil
+	}
+
+	_, err := writer.w.Write(output)
+	if err != nil {
+		return err
+	}
+	writer.pending = writer.pending[:0]
+	return nil
+}
+
+// redactingHandler masks registered values in messages and string attributes before handing records over
+type redactingHandler struct {
+	redactor *Redactor
+	handler  slog.Handler
+}
+
+// Handler wraps handler
+func (redactor *Redactor) Handler(handler slog.Handler) slog.Handler {
+	return &redactingHandler{redactor: redactor, handler: handler}
+}
+
+func (handler *redactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return handler.handler.Enabled(ctx, level)
+}
+
+func (handler *redactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	redacted := slog.NewRecord(record.Time, record.Level, handler.redactor.Redact(record.Message), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(handler.redactAttr(attr))
+		return true
+	})
+	return handler.handler.Handle(ctx, redacted)
+}
+
+func (handler *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, 0, len(attrs))
+	for _, attr := range attrs {
+		redacted = append(redacted, handler.redactAttr(attr))
+	}
+	return &redactingHandler{redactor: handler.redactor, handler: handler.handler.WithAttrs(redacted)}
+}
+
+func (handler *redactingHandler) WithGroup(name string) slog.Handler {
+	return &redactingHandler{redactor: handler.redactor, handler: handler.handler.WithGroup(name)}
+}
+
+func (handler *redactingHandler) redactAttr(attr slog.Attr) slog.Attr {
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, handler.redactor.Redact(value.String()))
+	case slog.KindGroup:
+		group := value.Group()
+		redacted := make([]any, 0, len(group))
+		for _, groupAttr := range group {
+			redacted = append(redacted, handler.redactAttr(groupAttr))
+		}
+		return slog.Group(attr.Key, redacted...)
+	case slog.KindAny:
+		// structs, errors, ... are only turned into strings when they contain a value
+		formatted := fmt.Sprintf("%+v", value.Any())
+		if redactedText := handler.redactor.Redact(formatted); redactedText != formatted {
+			return slog.String(attr.Key, redactedText)
+		}
+		return slog.Attr{Key: attr.Key, Value: value}
+	default:
+		return slog.Attr{Key: attr.Key, Value: value}
+	}
+}
+
+// ahoCorasick finds every occurrence of a set of byte strings in a single pass
+type ahoCorasick struct {
+	nodes []ahoCorasickNode
+}
+
+type ahoCorasickNode struct {
+	next map[byte]int32
+	fail int32
+	// length of the value prefix leading to this node
+	depth int32
+	// length of the longest pattern ending at this node, following fail links, 0 when none
+	output int32
+}
+
+func newAhoCorasick(patterns []string) *ahoCorasick {
+	matcher := &ahoCorasick{nodes: []ahoCorasickNode{{next: map[byte]int32{}}}}
+
+	for _, pattern := range patterns {
+		current := int32(0)
+		for i := 0; i < len(pattern); i++ {
+			child, ok := matcher.nodes[current].next[pattern[i]]
+			if !ok {
+				child = int32(len(matcher.nodes))
+				matcher.nodes = append(matcher.nodes, ahoCorasickNode{next: map[byte]int32{}, depth: int32(i + 1)})
+				matcher.nodes[current].next[pattern[i]] = child
+			}
+			current = child
+		}
+		matcher.nodes[current].output = int32(len(pattern))
+	}
+
+	// breadth first so fail links always point to an already processed node
+	queue := make([]int32, 0, len(matcher.nodes))
+	for _, child := range matcher.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for char, child := range matcher.nodes[current].next {
+			fail := matcher.nodes[current].fail
+			for {
+				if target, ok := matcher.nodes[fail].next[char]; ok && target != child {
+					matcher.nodes[child].fail = target
+					break
+				}
+				if fail == 0 {
+					break
+				}
+				fail = matcher.nodes[fail].fail
+			}
+
+			if failOutput := matcher.nodes[matcher.nodes[child].fail].output; failOutput > matcher.nodes[child].output {
+				matcher.nodes[child].output = failOutput
+			}
+			queue = append(queue, child)
+		}
+	}
+
+	return matcher
+}
+
+// findAll returns the sorted, merged [start, end) intervals covered by matches, and the length of the longest
+// suffix of input that is the beginning of a value
+func (matcher *ahoCorasick) findAll(input []byte) ([][2]int, int) {
+	var intervals [][2]int
+	current := int32(0)
+	for i := 0; i < len(input); i++ {
+		for {
+			if next, ok := matcher.nodes[current].next[input[i]]; ok {
+				current = next
+				break
+			}
+			if current == 0 {
+				break
+			}
+			current = matcher.nodes[current].fail
+		}
+
+		length := int(matcher.nodes[current].output)
+		if length == 0 {
+			continue
+		}
+
+		start, end := i+1-length, i+1
+		last := len(intervals) - 1
+		if last >= 0 && start <= intervals[last][1] {
+			if start < intervals[last][0] {
+				intervals[last][0] = start
+			}
+			intervals[last][1] = end
+			continue
+		}
+		intervals = append(intervals, [2]int{start, end})
+	}
+
+	// a late, long match may swallow earlier intervals
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i][0] < intervals[j][0] })
+	merged := intervals[:0]
+	for _, interval := range intervals {
+		last := len(merged) - 1
+		if last >= 0 && interval[0] <= merged[last][1] {
+			if interval[1] > merged[last][1] {
+				merged[last][1] = interval[1]
+			}
+			continue
+		}
+		merged = append(merged, interval)
+	}
+
+	return merged, int(matcher.nodes[current].depth)
+}
@@ -0,0 +1,319 @@
+package locker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		input  string
+		want   string
+	}{
+		{name: "no values", values: nil, input: "nothing to hide", want: "nothing to hide"},
+		{name: "single", values: []string{"s3cr3t"}, input: "token=s3cr3t;", want: "token=***;"},
+		{name: "repeated", values: []string{"s3cr3t"}, input: "s3cr3t s3cr3t", want: "*** ***"},
+		{name: "whole input", values: []string{"s3cr3t"}, input: "s3cr3t", want: "***"},
+		{name: "nested shorter value", values: []string{"pass", "password123"}, input: "pw=password123 p=pass", want: "pw=*** p=***"},
+		{name: "nested in the middle", values: []string{"word", "password123"}, input: "[password123]", want: "[***]"},
+		{name: "shorter value first in text", values: []string{"pass", "password123"}, input: "passpassword123", want: "***"},
+		{name: "overlapping", values: []string{"abcdef", "defghi"}, input: "xabcdefghix", want: "x***x"},
+		{name: "adjacent", values: []string{"aaaa", "bbbb"}, input: "aaaabbbb", want: "***"},
+		{name: "partial prefix restart", values: []string{"abcdabce"}, input: "abcdabcdabce", want: "abcd***"},
+		{name: "partial prefix restart twice", values: []string{"abcdabce"}, input: "abcdabcdabcdabce!", want: "abcdabcd***!"},
+		{name: "suffix of failed prefix", values: []string{"aab1", "ab12"}, input: "aab12", want: "***"},
+		{name: "partial match only", values: []string{"abcdabce"}, input: "abcdabc", want: "abcdabc"},
+		{name: "too short values are ignored", values: []string{"abc", "1234"}, input: "abc 1234", want: "abc ***"},
+		{name: "multi-byte", values: []string{"mật khẩu"}, input: "pw: mật khẩu.", want: "pw: ***."},
+		{name: "multi-line", values: []string{"line1\nline2"}, input: "a\nline1\nline2\nb", want: "a\n***\nb"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			redactor := NewRedactor(nil)
+			redactor.Add(test.values...)
+
+			got := redactor.Redact(test.input)
+			if got != test.want {
+				t.Fatalf("redact broke, expecting %q, getting %q instead", test.want, got)
+			}
+		})
+	}
+}
+
+func TestRedactorOptions(t *testing.T) {
+	redactor := NewRedactor(&RedactorOptions{Mask: "<hidden>", MinLength: 2})
+	redactor.Add("ab", "a", "ab")
+	if redactor.Len() != 1 {
+		t.Fatalf("redactor broke, expecting 1 value, getting %d instead", redactor.Len())
+	}
+	if got := redactor.Redact("a ab"); got != "a <hidden>" {
+		t.Fatalf("redactor broke, getting %q", got)
+	}
+
+	fingerprinted := NewRedactor(&RedactorOptions{Fingerprint: true})
+	fingerprinted.Add("first-secret", "second-secret")
+	got := fingerprinted.Redact("first-secret second-secret first-secret")
+	parts := strings.Split(got, " ")
+	if len(parts) != 3 || parts[0] != parts[2] || parts[0] == parts[1] || !strings.HasPrefix(parts[0], "***") || len(parts[0]) != 3+16 {
+		t.Fatalf("fingerprint redactor broke, getting %q", got)
+	}
+
+	other := NewRedactor(&RedactorOptions{Fingerprint: true})
+	other.Add("first-secret")
+	if other.Redact("first-secret") == parts[0] {
+		t.Fatalf("fingerprint redactor broke, fingerprints must be keyed per redactor")
+	}
+}
+
+func TestRedactorAddAfterUse(t *testing.T) {
+	redactor := NewRedactor(nil)
+	redactor.Add("first-secret")
+	if got := redactor.Redact("first-secret second-secret"); got != "*** second-secret" {
+		t.Fatalf("redactor broke, getting %q", got)
+	}
+
+	redactor.Add("second-secret")
+	if got := redactor.Redact("first-secret second-secret"); got != "*** ***" {
+		t.Fatalf("redactor broke, values added later must be matched, getting %q", got)
+	}
+}
+
+func TestRedactingWriter(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		writes []string
+		want   string
+	}{
+		{name: "single write", values: []string{"s3cr3t"}, writes: []string{"a s3cr3t b"}, want: "a *** b"},
+		{name: "split in two", values: []string{"s3cr3t"}, writes: []string{"a s3c", "r3t b"}, want: "a *** b"},
+		{name: "one byte per write", values: []string{"s3cr3t"}, writes: strings.Split("x s3cr3t y s3cr3t", ""), want: "x *** y ***"},
+		{name: "split at the end", values: []string{"s3cr3t"}, writes: []string{"a s3cr3", "t"}, want: "a ***"},
+		{name: "nested split", values: []string{"pass", "password123"}, writes: []string{"pw=pass", "word123 p=pa", "ss"}, want: "pw=*** p=***"},
+		{name: "partial prefix restart split", values: []string{"abcdabce"}, writes: []string{"abcdab", "cdab", "ce"}, want: "abcd***"},
+		{name: "false start", values: []string{"s3cr3t"}, writes: []string{"s3c", "ret s3", "cr3t"}, want: "s3cret ***"},
+		{name: "no values", values: nil, writes: []string{"a", "b"}, want: "ab"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			redactor := NewRedactor(nil)
+			redactor.Add(test.values...)
+
+			var output bytes.Buffer
+			writer := redactor.Writer(&output)
+			for _, data := range test.writes {
+				written, err := writer.Write([]byte(data))
+				if err != nil {
+					t.Fatalf("redacting writer broke, error: %v", err)
+				}
+				if written != len(data) {
+					t.Fatalf("redacting writer broke, expecting %d bytes written, getting %d instead", len(data), written)
+				}
+				for _, value := range test.values {
+					if strings.Contains(output.String(), value) {
+						t.Fatalf("redacting writer broke, %q leaked in %q", value, output.String())
+					}
+				}
+			}
+
+			err := writer.Close()
+			if err != nil {
+				t.Fatalf("redacting writer broke, error: %v", err)
+			}
+			if output.String() != test.want {
+				t.Fatalf("redacting writer broke, expecting %q, getting %q instead", test.want, output.String())
+			}
+		})
+	}
+}
+
+func TestRedactingWriterFlush(t *testing.T) {
+	redactor := NewRedactor(nil)
+	redactor.Add("s3cr3t")
+
+	var output bytes.Buffer
+	writer := redactor.Writer(&output)
+
+	_, _ = writer.Write([]byte("done s3c"))
+	// the possible beginning of a value is held back
+	if output.String() != "done " {
+		t.Fatalf("redacting writer broke, expecting %q before flush, getting %q instead", "done ", output.String())
+	}
+
+	err := writer.Flush()
+	if err != nil {
+		t.Fatalf("redacting writer broke, error: %v", err)
+	}
+	if output.String() != "done s3c" {
+		t.Fatalf("redacting writer broke, expecting the held back tail after flush, getting %q", output.String())
+	}
+
+	// a complete value held back is masked by Flush
+	_, _ = writer.Write([]byte(" s3cr3t"))
+	err = writer.Flush()
+	if err != nil {
+		t.Fatalf("redacting writer broke, error: %v", err)
+	}
+	if output.String() != "done s3c ***" {
+		t.Fatalf("redacting writer broke, getting %q", output.String())
+	}
+
+	err = writer.Flush()
+	if err != nil || output.String() != "done s3c ***" {
+		t.Fatalf("redacting writer broke, flushing twice must not write anything, getting %q", output.String())
+	}
+}
+
+type closeRecorder struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (recorder *closeRecorder) Close() error {
+	recorder.closed = true
+	return nil
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestRedactingWriterClose(t *testing.T) {
+	redactor := NewRedactor(nil)
+	redactor.Add("s3cr3t")
+
+	recorder := &closeRecorder{}
+	writer := redactor.Writer(recorder)
+	_, _ = writer.Write([]byte("tail s3cr3"))
+	err := writer.Close()
+	if err != nil {
+		t.Fatalf("redacting writer broke, error: %v", err)
+	}
+	if !recorder.closed || recorder.String() != "tail s3cr3" {
+		t.Fatalf("redacting writer broke, expecting flush then close, getting %q (closed: %v)", recorder.String(), recorder.closed)
+	}
+
+	_, err = redactor.Writer(failingWriter{}).Write([]byte("some text long enough to be written"))
+	if err == nil {
+		t.Fatalf("redacting writer broke, expecting the underlying write error")
+	}
+}
+
+type loggedError struct {
+	value string
+}
+
+func (loggedErr loggedError) Error() string {
+	return "connection failed with " + loggedErr.value
+}
+
+func TestRedactingHandler(t *testing.T) {
+	redactor := NewRedactor(nil)
+	redactor.Add("s3cr3t", "hunter22")
+
+	var output bytes.Buffer
+	handler := redactor.Handler(slog.NewTextHandler(&output, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			if attr.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return attr
+		},
+	}))
+	logger := slog.New(handler).With("password", "hunter22").WithGroup("request")
+
+	logger.Info("login with s3cr3t",
+		"token", "s3cr3t",
+		"count", 3,
+		slog.Group("headers", "authorization", "Bearer s3cr3t", "accept", "*/*"),
+		"error", loggedError{value: "hunter22"},
+		"plain", struct{ Name string }{Name: "visible"},
+		"lazy", slog.AnyValue(fmt.Stringer(nil)),
+	)
+
+	got := output.String()
+	for _, leaked := range []string{"s3cr3t", "hunter22"} {
+		if strings.Contains(got, leaked) {
+			t.Fatalf("redacting handler broke, %q leaked in %q", leaked, got)
+		}
+	}
+	for _, kept := range []string{
+		`msg="login with ***"`,
+		"password=***",
+		"request.token=***",
+		"request.count=3",
+		`request.headers.authorization="Bearer ***"`,
+		"request.headers.accept=*/*",
+		`request.error="connection failed with ***"`,
+		"request.plain={Name:visible}",
+	} {
+		if !strings.Contains(got, kept) {
+			t.Fatalf("redacting handler broke, expecting %q in %q", kept, got)
+		}
+	}
+
+	if !handler.Enabled(context.Background(), slog.LevelInfo) || handler.Enabled(context.Background(), slog.LevelDebug) {
+		t.Fatalf("redacting handler broke, levels must come from the wrapped handler")
+	}
+}
+
+type logValuer struct{}
+
+func (logValuer) LogValue() slog.Value {
+	return slog.StringValue("resolved s3cr3t")
+}
+
+func TestRedactingHandlerLogValuer(t *testing.T) {
+	redactor := NewRedactor(nil)
+	redactor.Add("s3cr3t")
+
+	var output bytes.Buffer
+	logger := slog.New(redactor.Handler(slog.NewJSONHandler(&output, nil)))
+	logger.Info("message", "value", logValuer{})
+
+	if strings.Contains(output.String(), "s3cr3t") || !strings.Contains(output.String(), `"value":"resolved ***"`) {
+		t.Fatalf("redacting handler broke, log valuers must be resolved before redaction, getting %q", output.String())
+	}
+}
+
+func TestClientRedactor(t *testing.T) {
+	var client Locker
+	client.rememberValue("decrypted-value")
+	if got := client.Redactor().Redact("x decrypted-value"); got != "x ***" {
+		t.Fatalf("client redactor broke, getting %q", got)
+	}
+
+	replacement := NewRedactor(&RedactorOptions{Mask: "#"})
+	client.SetRedactor(replacement)
+	client.rememberValue("other-value")
+	if got := replacement.Redact("decrypted-value other-value"); got != "decrypted-value #" {
+		t.Fatalf("client redactor broke, getting %q", got)
+	}
+}
+
+func TestRedactManyValues(t *testing.T) {
+	redactor := NewRedactor(nil)
+	var builder strings.Builder
+	for i := 0; i < 5000; i++ {
+		value := fmt.Sprintf("value-%05d-end", i)
+		redactor.Add(value)
+		builder.WriteString(value)
+		builder.WriteString(" ")
+	}
+
+	got := redactor.Redact(builder.String())
+	if strings.Contains(got, "value-") || strings.Count(got, "***") != 5000 {
+		t.Fatalf("redact broke with many values")
+	}
+}
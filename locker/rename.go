@@ -0,0 +1,108 @@
+package locker
+
+import (
+	"fmt"
+
+	"github.com/lockerpm/secrets-sdk-go/types"
+)
+
+// RenameSecret renames oldKey to newKey in env (nil means ALL), keeping the secret's ID, value and description.
+// It fails if newKey is already defined in that environment.
+func (locker *Locker) RenameSecret(oldKey, newKey string, env *string) (types.EncryptedSecResponse, error) {
+	err := validateRename(oldKey, newKey)
+	if err != nil {
+		return types.EncryptedSecResponse{}, err
+	}
+
+	scoped, err := locker.listScopedSecrets(env)
+	if err != nil {
+		return types.EncryptedSecResponse{}, err
+	}
+
+	if _, ok := scoped[oldKey]; !ok {
+		types.CURRENT_ERR = types.ERR_NOT_FOUND
+		return types.EncryptedSecResponse{}, fmt.Errorf("no secret found with provided name and env")
+	}
+	if _, ok := scoped[newKey]; ok {
+		types.CURRENT_ERR = types.ERR_INPUT
+		return types.EncryptedSecResponse{}, fmt.Errorf("secret %s already exists in this environment", newKey)
+	}
+
+	return locker.UpdateSecret(oldKey, env, &InputSecData{Key: &newKey})
+}
+
+// RenameSecretAllEnvironments renames oldKey to newKey in every environment defining it, ALL included.
+// Conflicts are checked everywhere before renaming anything, and renamed entries are reverted if one rename fails.
+func (locker *Locker) RenameSecretAllEnvironments(oldKey, newKey string) ([]types.EncryptedSecResponse, error) {
+	err := validateRename(oldKey, newKey)
+	if err != nil {
+		return nil, err
+	}
+
+	secObjs, err := locker.ListSecret(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	scopeOf := func(secObj types.Secret) string {
+		if secObj.EnvironmentHash == nil {
+			return ""
+		}
+		return *secObj.EnvironmentHash
+	}
+
+	taken := make(map[string]bool)
+	for _, secObj := range secObjs {
+		if secObj.Key == newKey {
+			taken[scopeOf(secObj)] = true
+		}
+	}
+
+	var targets []types.Secret
+	for _, secObj := range secObjs {
+		if secObj.Key != oldKey {
+			continue
+		}
+		if taken[scopeOf(secObj)] {
+			envName := "ALL"
+			if secObj.EnvironmentName != nil {
+				envName = *secObj.EnvironmentName
+			}
+			types.CURRENT_ERR = types.ERR_INPUT
+			return nil, fmt.Errorf("secret %s already exists in environment %s", newKey, envName)
+		}
+		targets = append(targets, secObj)
+	}
+
+	if len(targets) == 0 {
+		types.CURRENT_ERR = types.ERR_NOT_FOUND
+		return nil, fmt.Errorf("no secret found with provided name")
+	}
+
+	var results []types.EncryptedSecResponse
+	for i, secObj := range targets {
+		result, err := locker.RenameSecret(oldKey, newKey, secObj.EnvironmentName)
+		if err != nil {
+			// best effort: put back the keys renamed so far
+			for _, renamed := range targets[:i] {
+				_, _ = locker.RenameSecret(newKey, oldKey, renamed.EnvironmentName)
+			}
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func validateRename(oldKey, newKey string) error {
+	if oldKey == "" || newKey == "" {
+		types.CURRENT_ERR = types.ERR_INPUT
+		return fmt.Errorf("secret's old and new name must not be empty")
+	}
+	if oldKey == newKey {
+		types.CURRENT_ERR = types.ERR_INPUT
+		return fmt.Errorf("secret's new name must be different from the old one")
+	}
+	return nil
+}
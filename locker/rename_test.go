@@ -0,0 +1,123 @@
+package locker
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/lockerpm/secrets-sdk-go/types"
+)
+
+func TestRenameSecret(t *testing.T) {
+	server, client := newTestServer(t)
+	server.addEnvironment(t, "production")
+	secret := server.addSecret(t, "DB_PASS", "s3cr3t", "production")
+	server.addSecret(t, "DB_PASS", "all-value", "")
+	server.addSecret(t, "DB_USER", "app", "production")
+
+	prod := "production"
+	result, err := client.RenameSecret("DB_PASS", "DB_PASSWORD", &prod)
+	if err != nil {
+		t.Fatalf("rename secret broke, error: %v", err)
+	}
+	if result.ID != secret.ID {
+		t.Fatalf("rename secret broke, expecting the ID %s to be kept, getting %s", secret.ID, result.ID)
+	}
+	assertServerValue(t, server, "DB_PASSWORD", "production", "s3cr3t")
+	assertServerMissing(t, server, "DB_PASS", "production")
+	assertServerValue(t, server, "DB_PASS", "", "all-value")
+
+	tests := []struct {
+		name           string
+		oldKey, newKey string
+		err            string
+		code           string
+	}{
+		{name: "existing target", oldKey: "DB_PASSWORD", newKey: "DB_USER", err: "secret DB_USER already exists in this environment", code: types.ERR_INPUT},
+		{name: "missing", oldKey: "DB_PASS", newKey: "DB_PASS_2", err: "no secret found", code: types.ERR_NOT_FOUND},
+		{name: "same name", oldKey: "DB_USER", newKey: "DB_USER", err: "must be different", code: types.ERR_INPUT},
+		{name: "empty name", oldKey: "DB_USER", newKey: "", err: "must not be empty", code: types.ERR_INPUT},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := client.RenameSecret(test.oldKey, test.newKey, &prod)
+			if err == nil || !strings.Contains(err.Error(), test.err) || types.CURRENT_ERR != test.code {
+				t.Fatalf("rename secret broke, expecting %q (%s), getting %v (%s)", test.err, test.code, err, types.CURRENT_ERR)
+			}
+		})
+	}
+}
+
+func TestRenameSecretAllEnvironments(t *testing.T) {
+	server, client := newTestServer(t)
+	server.addEnvironment(t, "staging")
+	server.addEnvironment(t, "production")
+	server.addSecret(t, "DB_PASS", "all-value", "")
+	server.addSecret(t, "DB_PASS", "staging-value", "staging")
+	server.addSecret(t, "DB_PASS", "production-value", "production")
+
+	results, err := client.RenameSecretAllEnvironments("DB_PASS", "DB_PASSWORD")
+	if err != nil || len(results) != 3 {
+		t.Fatalf("rename secret in all environments broke, getting %d results (%v)", len(results), err)
+	}
+	for env, value := range map[string]string{"": "all-value", "staging": "staging-value", "production": "production-value"} {
+		assertServerValue(t, server, "DB_PASSWORD", env, value)
+		assertServerMissing(t, server, "DB_PASS", env)
+	}
+
+	_, err = client.RenameSecretAllEnvironments("DB_PASS", "DB_PASSWORD_2")
+	if err == nil || types.CURRENT_ERR != types.ERR_NOT_FOUND {
+		t.Fatalf("rename secret in all environments broke, expecting a not found error, getting %v", err)
+	}
+}
+
+func TestRenameSecretAllEnvironmentsConflict(t *testing.T) {
+	server, client := newTestServer(t)
+	server.addEnvironment(t, "staging")
+	server.addEnvironment(t, "production")
+	server.addSecret(t, "DB_PASS", "all-value", "")
+	server.addSecret(t, "DB_PASS", "staging-value", "staging")
+	server.addSecret(t, "DB_PASS", "production-value", "production")
+	server.addSecret(t, "DB_PASSWORD", "existing", "production")
+
+	// the conflict in production is found before anything is renamed
+	_, err := client.RenameSecretAllEnvironments("DB_PASS", "DB_PASSWORD")
+	if err == nil || err.Error() != "secret DB_PASSWORD already exists in environment production" {
+		t.Fatalf("rename secret in all environments broke, expecting a conflict, getting %v", err)
+	}
+	if calls := server.calls(); len(calls) != 0 {
+		t.Fatalf("rename secret in all environments broke, nothing must be renamed, getting %v", calls)
+	}
+}
+
+func TestRenameSecretAllEnvironmentsRollback(t *testing.T) {
+	server, client := newTestServer(t)
+	server.addEnvironment(t, "staging")
+	server.addSecret(t, "DB_PASS", "all-value", "")
+	server.addSecret(t, "DB_PASS", "staging-value", "staging")
+
+	// the second rename fails, the first one is reverted
+	renames := 0
+	server.fail = func(method, path string, body []byte) (int, string) {
+		if method == http.MethodPut {
+			renames++
+			if renames == 2 {
+				return http.StatusInternalServerError, "unavailable"
+			}
+		}
+		return 0, ""
+	}
+
+	_, err := client.RenameSecretAllEnvironments("DB_PASS", "DB_PASSWORD")
+	if err == nil {
+		t.Fatalf("rename secret in all environments broke, expecting an error")
+	}
+	if calls := server.calls(); len(calls) != 3 {
+		t.Fatalf("rename secret in all environments broke, expecting a rename, a failure and a revert, getting %v", calls)
+	}
+	for env, value := range map[string]string{"": "all-value", "staging": "staging-value"} {
+		assertServerValue(t, server, "DB_PASS", env, value)
+		assertServerMissing(t, server, "DB_PASSWORD", env)
+	}
+}
@@ -0,0 +1,78 @@
+package locker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/lockerpm/secrets-sdk-go/types"
+)
+
+type Rotator struct {
+	// Generate produces the new value, see RandomPassword, HexToken and UUID
+	Generate ValueGenerator
+	// Commit, when set, propagates the new value to the dependent system once it is stored
+	Commit func(ctx context.Context, newValue string) error
+	// Rollback, when set, runs after the previous value has been restored because Commit failed
+	Rollback func(ctx context.Context, previousValue string) error
+}
+
+// RotateSecret replaces the value of key in env with a generated one. If rotator.Commit fails, the previous value
+// is stored again and rotator.Rollback is called, the returned error then wraps every failure.
+func (locker *Locker) RotateSecret(ctx context.Context, key string, env *string, rotator Rotator) (types.EncryptedSecResponse, error) {
+	if rotator.Generate == nil {
+		types.CURRENT_ERR = types.ERR_INPUT
+		return types.EncryptedSecResponse{}, fmt.Errorf("rotator must have a generator")
+	}
+
+	current, err := locker.GetSecret(key, env)
+	if err != nil {
+		return types.EncryptedSecResponse{}, err
+	}
+
+	newValue, err := rotator.Generate(ctx, current)
+	if err != nil {
+		return types.EncryptedSecResponse{}, err
+	}
+	if newValue == "" {
+		types.CURRENT_ERR = types.ERR_INPUT
+		return types.EncryptedSecResponse{}, fmt.Errorf("generated value must not be empty")
+	}
+
+	if err := ctx.Err(); err != nil {
+		return types.EncryptedSecResponse{}, err
+	}
+
+	valueToStore := newValue
+	updateResult, err := locker.UpdateSecret(key, env, &InputSecData{Value: &valueToStore})
+	if err != nil {
+		return types.EncryptedSecResponse{}, err
+	}
+
+	// nothing was stored in dry-run mode, the dependent system must not change either
+	if rotator.Commit == nil || locker.DryRun {
+		return updateResult, nil
+	}
+
+	commitErr := rotator.Commit(ctx, newValue)
+	if commitErr == nil {
+		return updateResult, nil
+	}
+
+	errs := []error{fmt.Errorf("error committing rotated secret: %w", commitErr)}
+
+	previousValue := current.Value
+	_, err = locker.UpdateSecret(key, env, &InputSecData{Value: &previousValue})
+	if err != nil {
+		errs = append(errs, fmt.Errorf("error restoring previous value: %w", err))
+	}
+
+	if rotator.Rollback != nil {
+		err = rotator.Rollback(ctx, current.Value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error rolling back: %w", err))
+		}
+	}
+
+	return types.EncryptedSecResponse{}, errors.Join(errs...)
+}
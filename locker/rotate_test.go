@@ -0,0 +1,132 @@
+package locker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/lockerpm/secrets-sdk-go/types"
+)
+
+func staticGenerator(value string) ValueGenerator {
+	return func(ctx context.Context, current types.Secret) (string, error) {
+		return value, nil
+	}
+}
+
+func TestRotateSecret(t *testing.T) {
+	server, client := newTestServer(t)
+	server.addSecret(t, "API_TOKEN", "old-token", "")
+
+	var committed string
+	_, err := client.RotateSecret(context.Background(), "API_TOKEN", nil, Rotator{
+		Generate: staticGenerator("new-token"),
+		Commit: func(ctx context.Context, newValue string) error {
+			committed = newValue
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("rotate secret broke, error: %v", err)
+	}
+	if committed != "new-token" {
+		t.Fatalf("rotate secret broke, expecting the new value committed, getting %q", committed)
+	}
+	assertServerValue(t, server, "API_TOKEN", "", "new-token")
+}
+
+func TestRotateSecretRollback(t *testing.T) {
+	server, client := newTestServer(t)
+	server.addSecret(t, "API_TOKEN", "old-token", "")
+
+	errCommit := errors.New("dependent system refused the token")
+	var rolledBack string
+	_, err := client.RotateSecret(context.Background(), "API_TOKEN", nil, Rotator{
+		Generate: staticGenerator("new-token"),
+		Commit: func(ctx context.Context, newValue string) error {
+			assertServerValue(t, server, "API_TOKEN", "", "new-token")
+			return errCommit
+		},
+		Rollback: func(ctx context.Context, previousValue string) error {
+			rolledBack = previousValue
+			return nil
+		},
+	})
+	if !errors.Is(err, errCommit) {
+		t.Fatalf("rotate secret broke, expecting the commit error, getting %v", err)
+	}
+	if rolledBack != "old-token" {
+		t.Fatalf("rotate secret broke, expecting the rollback with the previous value, getting %q", rolledBack)
+	}
+	if calls := server.calls(); len(calls) != 2 {
+		t.Fatalf("rotate secret broke, expecting the update and the restore, getting %v", calls)
+	}
+	assertServerValue(t, server, "API_TOKEN", "", "old-token")
+}
+
+func TestRotateSecretRestoreFailure(t *testing.T) {
+	server, client := newTestServer(t)
+	server.addSecret(t, "API_TOKEN", "old-token", "")
+
+	// the update goes through, restoring the previous value fails
+	updates := 0
+	server.fail = func(method, path string, body []byte) (int, string) {
+		if method == http.MethodPut {
+			updates++
+			if updates == 2 {
+				return http.StatusInternalServerError, "unavailable"
+			}
+		}
+		return 0, ""
+	}
+
+	rolledBack := false
+	_, err := client.RotateSecret(context.Background(), "API_TOKEN", nil, Rotator{
+		Generate: staticGenerator("new-token"),
+		Commit: func(ctx context.Context, newValue string) error {
+			return errors.New("commit failed")
+		},
+		Rollback: func(ctx context.Context, previousValue string) error {
+			rolledBack = true
+			return errors.New("rollback failed")
+		},
+	})
+	for _, message := range []string{"error committing rotated secret: commit failed", "error restoring previous value", "error rolling back: rollback failed"} {
+		if err == nil || !strings.Contains(err.Error(), message) {
+			t.Fatalf("rotate secret broke, expecting %q in the error, getting %v", message, err)
+		}
+	}
+	if !rolledBack {
+		t.Fatalf("rotate secret broke, the rollback must run even if the restore failed")
+	}
+	assertServerValue(t, server, "API_TOKEN", "", "new-token")
+}
+
+func TestRotateSecretUpdateFailure(t *testing.T) {
+	server, client := newTestServer(t)
+	server.addSecret(t, "API_TOKEN", "old-token", "")
+
+	server.fail = func(method, path string, body []byte) (int, string) {
+		return http.StatusInternalServerError, "unavailable"
+	}
+
+	committed := false
+	_, err := client.RotateSecret(context.Background(), "API_TOKEN", nil, Rotator{
+		Generate: staticGenerator("new-token"),
+		Commit: func(ctx context.Context, newValue string) error {
+			committed = true
+			return nil
+		},
+	})
+	if err == nil || committed {
+		t.Fatalf("rotate secret broke, nothing must be committed when the update fails, getting %v", err)
+	}
+	assertServerValue(t, server, "API_TOKEN", "", "old-token")
+
+	_, err = client.RotateSecret(context.Background(), "API_TOKEN", nil, Rotator{Generate: staticGenerator("")})
+	if err == nil || types.CURRENT_ERR != types.ERR_INPUT {
+		t.Fatalf("rotate secret broke, expecting an error for an empty value, getting %v", err)
+	}
+}
@@ -0,0 +1,153 @@
+package locker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/lockerpm/secrets-sdk-go/types"
+)
+
+type RunOptions struct {
+	// Precedence decides who wins when a secret and an existing variable share a name,
+	// either types.PRECEDENCE_SECRET (default) or types.PRECEDENCE_OS
+	Precedence string
+	// Signals forwarded to the child process, defaults to os.Interrupt and SIGTERM
+	Signals []os.Signal
+}
+
+// Run starts cmd with every secret of env (falling back to ALL) merged into its environment,
+// forwards signals to it and returns its exit code. cmd.Env is used as the base environment when set,
+// otherwise the current process environment is. When ctx is done the child is killed and ctx's error is returned,
+// unless the child already exited.
+func (locker *Locker) Run(ctx context.Context, env *string, cmd *exec.Cmd, opts *RunOptions) (int, error) {
+	if cmd == nil {
+		types.CURRENT_ERR = types.ERR_INPUT
+		return -1, fmt.Errorf("command must not be empty")
+	}
+
+	if opts == nil {
+		opts = &RunOptions{}
+	}
+
+	precedence := opts.Precedence
+	if precedence == "" {
+		precedence = types.PRECEDENCE_SECRET
+	}
+	if precedence != types.PRECEDENCE_SECRET && precedence != types.PRECEDENCE_OS {
+		types.CURRENT_ERR = types.ERR_INPUT
+		return -1, fmt.Errorf("invalid precedence %q", opts.Precedence)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return -1, err
+	}
+
+	secrets, err := locker.listRunSecrets(env)
+	if err != nil {
+		return -1, err
+	}
+
+	values := make(map[string]string, len(secrets))
+	for key, secObj := range secrets {
+		values[key] = secObj.Value
+	}
+
+	baseEnv := cmd.Env
+	if baseEnv == nil {
+		baseEnv = os.Environ()
+	}
+	cmd.Env = mergeEnviron(baseEnv, values, precedence)
+
+	signals := opts.Signals
+	if len(signals) == 0 {
+		signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, signals...)
+	defer signal.Stop(sigChan)
+
+	err = cmd.Start()
+	if err != nil {
+		types.CURRENT_ERR = types.ERR_FUNC
+		return -1, fmt.Errorf("error starting command: %w", err)
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	killed := false
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case sig := <-sigChan:
+				_ = cmd.Process.Signal(sig)
+			case <-ctx.Done():
+				killed = cmd.Process.Kill() == nil
+				return
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err = cmd.Wait()
+	close(done)
+	<-stopped
+
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			// a child killed by the cancellation reports ctx's error, one that exited on its own keeps its status
+			if killed && exitErr.ExitCode() == -1 {
+				return -1, ctx.Err()
+			}
+			return exitErr.ExitCode(), nil
+		}
+		types.CURRENT_ERR = types.ERR_FUNC
+		return -1, fmt.Errorf("error running command: %w", err)
+	}
+
+	return 0, nil
+}
+
+// mergeEnviron merges values into a KEY=VALUE list, keeping the base order and appending new keys sorted
+func mergeEnviron(base []string, values map[string]string, precedence string) []string {
+	merged := make([]string, 0, len(base)+len(values))
+	seen := make(map[string]bool, len(base))
+
+	for _, entry := range base {
+		name, _, _ := strings.Cut(entry, "=")
+		seen[name] = true
+		if value, ok := values[name]; ok && precedence == types.PRECEDENCE_SECRET {
+			merged = append(merged, name+"="+value)
+			continue
+		}
+		merged = append(merged, entry)
+	}
+
+	var names []string
+	for name := range values {
+		if seen[name] || !isValidEnvName(name) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		merged = append(merged, name+"="+values[name])
+	}
+
+	return merged
+}
+
+func isValidEnvName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, "=\x00")
+}
@@ -0,0 +1,105 @@
+package locker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lockerpm/secrets-sdk-go/types"
+)
+
+func TestMergeEnviron(t *testing.T) {
+	base := []string{"PATH=/bin", "SHARED=os", "EMPTY="}
+	values := map[string]string{"SHARED": "secret", "NEW_B": "b", "NEW_A": "a", "BAD=NAME": "x", "": "y"}
+
+	tests := []struct {
+		precedence string
+		want       []string
+	}{
+		{types.PRECEDENCE_SECRET, []string{"PATH=/bin", "SHARED=secret", "EMPTY=", "NEW_A=a", "NEW_B=b"}},
+		{types.PRECEDENCE_OS, []string{"PATH=/bin", "SHARED=os", "EMPTY=", "NEW_A=a", "NEW_B=b"}},
+	}
+
+	for _, test := range tests {
+		merged := mergeEnviron(base, values, test.precedence)
+		if !reflect.DeepEqual(merged, test.want) {
+			t.Fatalf("mergeEnviron broke for %s, expecting %v, getting %v instead", test.precedence, test.want, merged)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	server, client := newTestServer(t)
+	server.addEnvironment(t, "production")
+	server.addSecret(t, "FALLBACK", "from-all", "")
+	server.addSecret(t, "SHARED", "all-value", "")
+	server.addSecret(t, "SHARED", "production-value", "production")
+
+	// a lookup of a missing key must not drop the cached ALL rows of the next run
+	_, err := client.GetSecret("MISSING", nil)
+	if err == nil {
+		t.Fatalf("get secret broke, expecting an error")
+	}
+
+	prod := "production"
+	tests := []struct {
+		name string
+		env  *string
+		opts *RunOptions
+		want string
+	}{
+		{name: "environment", env: &prod, want: "production-value|from-all"},
+		{name: "ALL", env: nil, want: "all-value|from-all"},
+		{name: "os precedence", env: &prod, opts: &RunOptions{Precedence: types.PRECEDENCE_OS}, want: "os-value|from-all"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var output bytes.Buffer
+			cmd := exec.Command("sh", "-c", `printf '%s|%s' "$SHARED" "$FALLBACK"`)
+			cmd.Env = []string{"PATH=" + os.Getenv("PATH"), "SHARED=os-value"}
+			cmd.Stdout = &output
+
+			code, err := client.Run(context.Background(), test.env, cmd, test.opts)
+			if err != nil || code != 0 {
+				t.Fatalf("run broke, exit code %d, error: %v", code, err)
+			}
+			if output.String() != test.want {
+				t.Fatalf("run broke, expecting %q, getting %q instead", test.want, output.String())
+			}
+		})
+	}
+}
+
+func TestRunExitCode(t *testing.T) {
+	server, client := newTestServer(t)
+	server.addSecret(t, "TOKEN", "value", "")
+
+	code, err := client.Run(context.Background(), nil, exec.Command("sh", "-c", "exit 3"), nil)
+	if err != nil || code != 3 {
+		t.Fatalf("run broke, expecting exit code 3, getting %d (%v)", code, err)
+	}
+
+	_, err = client.Run(context.Background(), nil, exec.Command("sh", "-c", "exit 0"), &RunOptions{Precedence: "unknown"})
+	if err == nil {
+		t.Fatalf("run broke, expecting an error for an invalid precedence")
+	}
+
+	_, err = client.Run(context.Background(), nil, exec.Command("/nonexistent/command"), nil)
+	if err == nil {
+		t.Fatalf("run broke, expecting an error for a command that cannot start")
+	}
+
+	// a child killed by the cancellation reports the context's error
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	code, err = client.Run(ctx, nil, exec.Command("sleep", "10"), nil)
+	if !errors.Is(err, context.DeadlineExceeded) || code != -1 {
+		t.Fatalf("run broke, expecting the context's error, getting %d (%v)", code, err)
+	}
+}
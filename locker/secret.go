@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"strings"
 
 	"github.com/lockerpm/secrets-sdk-go/types"
@@ -19,6 +21,104 @@ type InputSecData struct {
 	Desc  *string `json:"description,omitempty"`
 	EnvID *string `json:"environment_id,omitempty"`
 	Env   *string `json:"environment_name,omitempty"`
+	// Generate, when set instead of Value, stores a value generated with GenerateSecretValue
+	Generate *SecretPolicy `json:"-"`
+}
+
+type inputSecDataAlias InputSecData
+
+// Reveal returns the plaintext value, empty when none is set
+func (input InputSecData) Reveal() string {
+	if input.Value == nil {
+		return ""
+	}
+	return *input.Value
+}
+
+// RevealedJSON returns a value encoding input with its plaintext value, used for the request bodies
+func (input InputSecData) RevealedJSON() interface{} {
+	return inputSecDataAlias(input)
+}
+
+func (input InputSecData) redacted() inputSecDataAlias {
+	if input.Value != nil {
+		redacted := types.REDACTED
+		input.Value = &redacted
+	}
+	return inputSecDataAlias(input)
+}
+
+// Format prints input with its value redacted, pointers are printed as the values they point to
+func (input InputSecData) Format(state fmt.State, verb rune) {
+	if verb == 'v' && state.Flag('#') {
+		_, _ = io.WriteString(state, input.GoString())
+		return
+	}
+
+	redacted := input.redacted()
+	fmt.Fprintf(state, fmt.FormatString(state, verb), struct {
+		Key      string
+		Hash     string
+		Value    string
+		Desc     string
+		EnvID    string
+		Env      string
+		Generate *SecretPolicy
+	}{
+		Key:      derefString(redacted.Key),
+		Hash:     redacted.Hash,
+		Value:    derefString(redacted.Value),
+		Desc:     derefString(redacted.Desc),
+		EnvID:    derefString(redacted.EnvID),
+		Env:      derefString(redacted.Env),
+		Generate: redacted.Generate,
+	})
+}
+
+func (input InputSecData) GoString() string {
+	return strings.Replace(fmt.Sprintf("%#v", input.redacted()), "locker.inputSecDataAlias", "locker.InputSecData", 1)
+}
+
+func (input InputSecData) LogValue() slog.Value {
+	attrs := []slog.Attr{}
+	if input.Key != nil {
+		attrs = append(attrs, slog.String("key", *input.Key))
+	}
+	if input.Value != nil {
+		attrs = append(attrs, slog.String("value", types.REDACTED))
+	}
+	if input.Desc != nil {
+		attrs = append(attrs, slog.String("description", *input.Desc))
+	}
+	if input.Env != nil {
+		attrs = append(attrs, slog.String("environment_name", *input.Env))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// MarshalJSON encodes input with its value redacted, see types.MarshalRevealed
+func (input InputSecData) MarshalJSON() ([]byte, error) {
+	return json.Marshal(input.redacted())
+}
+
+// generateValue fills Value from the Generate policy
+func (input *InputSecData) generateValue() error {
+	if input.Generate == nil {
+		return nil
+	}
+	if input.Value != nil {
+		types.CURRENT_ERR = types.ERR_INPUT
+		return fmt.Errorf("secret's value and generation policy are mutually exclusive")
+	}
+
+	value, err := GenerateSecretValue(*input.Generate)
+	if err != nil {
+		return err
+	}
+	input.Value = &value
+	input.Generate = nil
+
+	return nil
 }
 
 func (locker *Locker) GetSecret(key string, env *string) (types.Secret, error) {
@@ -87,6 +187,7 @@ func (locker *Locker) GetSecret(key string, env *string) (types.Secret, error) {
 	if err != nil {
 		return types.Secret{}, err
 	}
+	locker.rememberValue(secObj.Value)
 
 	// err = locker.processOutputDecryption(secObj, types.FETCH_KIND_SEC, locker.hash)
 	// if err != nil {
@@ -160,6 +261,7 @@ func (locker *Locker) ListSecret(env *string) ([]types.Secret, error) {
 		if err != nil {
 			return []types.Secret{}, err
 		}
+		locker.rememberValue(secObjs[i].Value)
 	}
 
 	if locker.Export {
@@ -168,13 +270,164 @@ func (locker *Locker) ListSecret(env *string) ([]types.Secret, error) {
 	return secObjs, nil
 }
 
+// syncSecrets brings the local secret cache up to date and loads the keys, like ListSecret without reading the rows
+func (locker *Locker) syncSecrets() error {
+	err := locker.prepare("", types.FETCH_KIND_SEC)
+	if err != nil {
+		return err
+	}
+
+	// the full fetch found no secret on the server, every cached row is gone
+	if locker.emptyFetch {
+		result := locker.dBConn.Where("TRUE").Delete(&types.Secret{})
+		if result.Error != nil {
+			types.CURRENT_ERR = types.ERR_DB
+			return fmt.Errorf("error deleting secret: %v", result.Error)
+		}
+		return nil
+	}
+
+	var localCount int64
+	result := locker.dBConn.Model(&types.Secret{}).Count(&localCount)
+	if result.Error != nil {
+		types.CURRENT_ERR = types.ERR_DB
+		return fmt.Errorf("error querying secret: %v", result.Error)
+	}
+
+	// last ditch effort
+	if localCount == 0 {
+		return locker.fetchDataFromServer("", types.RevisionDate{}, types.FETCH_KIND_SEC)
+	}
+
+	return nil
+}
+
+// resolveSecrets flattens decrypted secrets into a key -> secret view of env,
+// environment-scoped values take precedence over the ones shared by ALL (env == nil)
+func (locker *Locker) resolveSecrets(secObjs []types.Secret, env *string) (map[string]types.Secret, error) {
+	var envHash string
+	var err error
+	if env != nil {
+		envHash, err = locker.getHash(*env)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	resolved := make(map[string]types.Secret)
+	for _, secObj := range secObjs {
+		if secObj.EnvironmentHash == nil || *secObj.EnvironmentHash == "" {
+			if _, ok := resolved[secObj.Key]; !ok {
+				resolved[secObj.Key] = secObj
+			}
+			continue
+		}
+
+		if env != nil && *secObj.EnvironmentHash == envHash {
+			resolved[secObj.Key] = secObj
+		}
+	}
+
+	return resolved, nil
+}
+
+// listScopedSecrets returns the secrets defined directly in env (ALL when env == nil) keyed by secret key,
+// without any fallback
+func (locker *Locker) listScopedSecrets(env *string) (map[string]types.Secret, error) {
+	secObjs, err := locker.ListSecret(env)
+	if err != nil {
+		return nil, err
+	}
+
+	scoped := make(map[string]types.Secret, len(secObjs))
+	for _, secObj := range secObjs {
+		if env == nil && secObj.EnvironmentHash != nil && *secObj.EnvironmentHash != "" {
+			continue
+		}
+		scoped[secObj.Key] = secObj
+	}
+
+	return scoped, nil
+}
+
+// listRunSecrets returns every secret visible to env, falling back to ALL for keys the environment does not define
+func (locker *Locker) listRunSecrets(env *string) (map[string]types.Secret, error) {
+	if env == nil {
+		secObjs, err := locker.ListSecret(nil)
+		if err != nil {
+			return nil, err
+		}
+		return locker.resolveSecrets(secObjs, nil)
+	}
+
+	_, err := locker.GetEnvironment(*env)
+	if err != nil {
+		return nil, err
+	}
+
+	// the environment_id filtered fetch never returns the ALL rows the environment falls back to,
+	// the regular sync refreshes both
+	err = locker.syncSecrets()
+	if err != nil {
+		return nil, err
+	}
+
+	envHash, err := locker.getHash(*env)
+	if err != nil {
+		return nil, err
+	}
+
+	var secObjs []types.Secret
+	result := locker.dBConn.Where("environment_hash = ? OR environment_hash is NULL", envHash).Find(&secObjs)
+	if result.Error != nil && result.RowsAffected != 0 {
+		types.CURRENT_ERR = types.ERR_DB
+		return nil, fmt.Errorf("error querying secret: %v", result.Error)
+	}
+
+	for i := range secObjs {
+		err = dataDecryption(&secObjs[i], locker.symKey, locker.macKey)
+		if err != nil {
+			return nil, err
+		}
+		locker.rememberValue(secObjs[i].Value)
+	}
+
+	return locker.resolveSecrets(secObjs, env)
+}
+
 func (locker *Locker) CreateSecret(input *InputSecData) (types.EncryptedSecResponse, error) {
+	var key string
+	var env *string
+	if input != nil {
+		key, env = derefString(input.Key), copyString(input.Env)
+	}
+
+	createResult, err := locker.createSecret(input)
+	return createResult, locker.audit(types.OPERATION_CREATE, types.FETCH_KIND_SEC, createResult.ID, key, env, err)
+}
+
+func (locker *Locker) createSecret(input *InputSecData) (types.EncryptedSecResponse, error) {
 	locker.currentOperation = types.OPERATION_CREATE
-	if input == nil || input.Key == nil || input.Value == nil {
+	if input == nil || input.Key == nil || (input.Value == nil && input.Generate == nil) {
 		return types.EncryptedSecResponse{}, fmt.Errorf("secret's name and value must not be empty")
 	}
 
-	err := locker.prepare(*input.Key, types.FETCH_KIND_SEC)
+	err := input.generateValue()
+	if err != nil {
+		return types.EncryptedSecResponse{}, err
+	}
+
+	err = checkSecretFileMarker(*input.Value)
+	if err != nil {
+		return types.EncryptedSecResponse{}, err
+	}
+
+	err = locker.ValidateSecret(*input.Key, *input.Value, input.Env)
+	if err != nil {
+		return types.EncryptedSecResponse{}, err
+	}
+
+	err = locker.prepare(*input.Key, types.FETCH_KIND_SEC)
 	if err != nil {
 		return types.EncryptedSecResponse{}, err
 	}
@@ -194,17 +447,52 @@ func (locker *Locker) CreateSecret(input *InputSecData) (types.EncryptedSecRespo
 		envPreEnc = input.Env
 	}
 
+	var planned types.EncryptedSecResponse
+	if locker.DryRun {
+		planned = types.EncryptedSecResponse{
+			Key:             keyPreEnc,
+			Value:           *input.Value,
+			Description:     derefString(input.Desc),
+			SecretHash:      tmpHash,
+			EnvironmentID:   input.EnvID,
+			EnvironmentName: copyString(input.Env),
+		}
+	}
+
 	err = dataEncryption(input, locker.symKey, locker.macKey)
 	if err != nil {
 		return types.EncryptedSecResponse{}, err
 	}
 	input.Hash = tmpHash
 
-	jsonBody, err := json.MarshalIndent(input, "", "  ")
+	jsonBody, err := types.MarshalIndentRevealed(input, "", "  ")
 	if err != nil {
 		return types.EncryptedSecResponse{}, err
 	}
 
+	if locker.DryRun {
+		if planned.EnvironmentName != nil {
+			envHash, err := locker.getHash(*planned.EnvironmentName)
+			if err != nil {
+				return types.EncryptedSecResponse{}, err
+			}
+			planned.EnvironmentHash = &envHash
+		}
+
+		err = locker.checkSecretDuplicate(tmpHash, planned.EnvironmentHash, "")
+		if err != nil {
+			return types.EncryptedSecResponse{}, err
+		}
+
+		err = locker.planCall(PlannedCall{
+			Kind:        types.FETCH_KIND_SEC,
+			Name:        keyPreEnc,
+			Environment: derefString(planned.EnvironmentName),
+			Hash:        tmpHash,
+		}, jsonBody)
+		return planned, err
+	}
+
 	createResult, err := createItem[types.EncryptedSecResponse](locker, types.FETCH_KIND_SEC, jsonBody)
 	if err != nil {
 		return types.EncryptedSecResponse{}, err
@@ -255,6 +543,7 @@ func (locker *Locker) CreateSecret(input *InputSecData) (types.EncryptedSecRespo
 		if err != nil {
 			return types.EncryptedSecResponse{}, err
 		}
+		locker.rememberValue(createResult.Value)
 
 	}
 
@@ -262,12 +551,29 @@ func (locker *Locker) CreateSecret(input *InputSecData) (types.EncryptedSecRespo
 }
 
 func (locker *Locker) UpdateSecret(key string, env *string, input *InputSecData) (types.EncryptedSecResponse, error) {
+	editResult, err := locker.updateSecret(key, env, input)
+	return editResult, locker.audit(types.OPERATION_UPDATE, types.FETCH_KIND_SEC, editResult.ID, key, env, err)
+}
+
+func (locker *Locker) updateSecret(key string, env *string, input *InputSecData) (types.EncryptedSecResponse, error) {
 	locker.currentOperation = types.OPERATION_UPDATE
 	if input == nil {
 		return types.EncryptedSecResponse{}, fmt.Errorf("there must be atleast one field in update data")
 	}
 
-	err := locker.prepare(key, types.FETCH_KIND_SEC)
+	err := input.generateValue()
+	if err != nil {
+		return types.EncryptedSecResponse{}, err
+	}
+
+	if input.Value != nil {
+		err = checkSecretFileMarker(*input.Value)
+		if err != nil {
+			return types.EncryptedSecResponse{}, err
+		}
+	}
+
+	err = locker.prepare(key, types.FETCH_KIND_SEC)
 	if err != nil {
 		return types.EncryptedSecResponse{}, err
 	}
@@ -277,6 +583,25 @@ func (locker *Locker) UpdateSecret(key string, env *string, input *InputSecData)
 		return types.EncryptedSecResponse{}, err
 	}
 
+	// a renamed key is checked against the rules of its new name even when the value is unchanged
+	if input.Key != nil || input.Value != nil {
+		newKey, newValue := getSecretResult.Key, getSecretResult.Value
+		if input.Key != nil {
+			newKey = *input.Key
+		}
+		if input.Value != nil {
+			newValue = *input.Value
+		}
+		targetEnv := env
+		if input.Env != nil {
+			targetEnv = input.Env
+		}
+		err = locker.ValidateSecret(newKey, newValue, targetEnv)
+		if err != nil {
+			return types.EncryptedSecResponse{}, err
+		}
+	}
+
 	if input.Env != nil {
 		if *input.Env != "" {
 			getEnvironmentResult, err := locker.GetEnvironment(*input.Env)
@@ -297,6 +622,43 @@ func (locker *Locker) UpdateSecret(key string, env *string, input *InputSecData)
 		keyPreEnc = key
 	}
 
+	var planned types.EncryptedSecResponse
+	if locker.DryRun {
+		planned = types.EncryptedSecResponse{
+			Object:          getSecretResult.Object,
+			ID:              getSecretResult.ID,
+			CreationDate:    getSecretResult.CreationDate,
+			RevisionDate:    getSecretResult.RevisionDate,
+			UpdatedDate:     getSecretResult.UpdatedDate,
+			LastUseDate:     getSecretResult.LastUseDate,
+			ProjectID:       getSecretResult.ProjectID,
+			EnvironmentID:   getSecretResult.EnvironmentID,
+			EnvironmentName: getSecretResult.EnvironmentName,
+			EnvironmentHash: getSecretResult.EnvironmentHash,
+			Key:             keyPreEnc,
+			Value:           getSecretResult.Value,
+			Description:     getSecretResult.Description,
+		}
+		if input.Value != nil {
+			planned.Value = *input.Value
+		}
+		if input.Desc != nil {
+			planned.Description = *input.Desc
+		}
+		if input.Env != nil {
+			planned.EnvironmentID, planned.EnvironmentName, planned.EnvironmentHash = nil, nil, nil
+			if *input.Env != "" {
+				envHash, err := locker.getHash(*input.Env)
+				if err != nil {
+					return types.EncryptedSecResponse{}, err
+				}
+				planned.EnvironmentID = copyString(input.EnvID)
+				planned.EnvironmentName = copyString(input.Env)
+				planned.EnvironmentHash = &envHash
+			}
+		}
+	}
+
 	err = dataEncryption(input, locker.symKey, locker.macKey)
 	if err != nil {
 		return types.EncryptedSecResponse{}, err
@@ -307,11 +669,28 @@ func (locker *Locker) UpdateSecret(key string, env *string, input *InputSecData)
 		return types.EncryptedSecResponse{}, err
 	}
 
-	jsonBody, err := json.MarshalIndent(input, "", "  ")
+	jsonBody, err := types.MarshalIndentRevealed(input, "", "  ")
 	if err != nil {
 		return types.EncryptedSecResponse{}, err
 	}
 
+	if locker.DryRun {
+		planned.SecretHash = input.Hash
+		err = locker.checkSecretDuplicate(planned.SecretHash, planned.EnvironmentHash, planned.ID)
+		if err != nil {
+			return types.EncryptedSecResponse{}, err
+		}
+
+		err = locker.planCall(PlannedCall{
+			Kind:        types.FETCH_KIND_SEC,
+			ItemID:      planned.ID,
+			Name:        keyPreEnc,
+			Environment: derefString(planned.EnvironmentName),
+			Hash:        planned.SecretHash,
+		}, jsonBody)
+		return planned, err
+	}
+
 	editResult, err := editItem[types.EncryptedSecResponse](locker, types.FETCH_KIND_SEC, getSecretResult.ID, jsonBody)
 	if err != nil {
 		return types.EncryptedSecResponse{}, err
@@ -336,8 +715,8 @@ func (locker *Locker) UpdateSecret(key string, env *string, input *InputSecData)
 			Description:     editResult.Description,
 		}
 
-		result := locker.dBConn.Save(&dataToUpdate)
-		if result.Error != nil {
+		err = locker.saveSecretCache(dataToUpdate)
+		if err != nil {
 			return types.EncryptedSecResponse{}, err
 		}
 
@@ -345,7 +724,60 @@ func (locker *Locker) UpdateSecret(key string, env *string, input *InputSecData)
 		if err != nil {
 			return types.EncryptedSecResponse{}, err
 		}
+		locker.rememberValue(editResult.Value)
 	}
 
 	return *editResult, nil
 }
+
+// DeleteSecret deletes key from env (ALL when env == nil), without falling back to ALL
+func (locker *Locker) DeleteSecret(key string, env *string) error {
+	locker.currentOperation = types.OPERATION_DELETE
+
+	getSecretResult, err := locker.GetSecret(key, env)
+	if err != nil {
+		return locker.audit(types.OPERATION_DELETE, types.FETCH_KIND_SEC, "", key, env, err)
+	}
+	if env != nil && getSecretResult.EnvironmentHash == nil {
+		types.CURRENT_ERR = types.ERR_NOT_FOUND
+		err = fmt.Errorf("no secret found with provided name and env")
+		return locker.audit(types.OPERATION_DELETE, types.FETCH_KIND_SEC, "", key, env, err)
+	}
+
+	if locker.DryRun {
+		return locker.planCall(PlannedCall{
+			Kind:        types.FETCH_KIND_SEC,
+			ItemID:      getSecretResult.ID,
+			Name:        key,
+			Environment: derefString(env),
+			Hash:        getSecretResult.SecretHash,
+		}, nil)
+	}
+
+	err = locker.deleteItem(types.FETCH_KIND_SEC, getSecretResult.ID)
+	return locker.audit(types.OPERATION_DELETE, types.FETCH_KIND_SEC, getSecretResult.ID, key, env, err)
+}
+
+// saveSecretCache replaces the cached row of secObj in a single transaction, dropping any other row left
+// under the same (environment_hash, secret_hash) so a renamed key never collides with a stale entry
+func (locker *Locker) saveSecretCache(secObj types.Secret) error {
+	err := locker.dBConn.Transaction(func(tx *gorm.DB) error {
+		var result *gorm.DB
+		if secObj.EnvironmentHash == nil {
+			result = tx.Where("secret_hash = ? AND environment_hash is NULL AND id <> ?", secObj.SecretHash, secObj.ID).Delete(&types.Secret{})
+		} else {
+			result = tx.Where("secret_hash = ? AND environment_hash = ? AND id <> ?", secObj.SecretHash, *secObj.EnvironmentHash, secObj.ID).Delete(&types.Secret{})
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+
+		return tx.Save(&secObj).Error
+	})
+	if err != nil {
+		types.CURRENT_ERR = types.ERR_DB
+		return fmt.Errorf("error caching secret: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package locker
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/lockerpm/secrets-sdk-go/types"
+)
+
+// DefaultMaxSecretFileSize is the largest file CreateSecretFile accepts unless told otherwise
+const DefaultMaxSecretFileSize = 256 << 10
+
+const defaultSecretFileType = "application/octet-stream"
+
+// file secrets are stored base64 encoded behind a versioned marker, plain values starting with the marker are
+// rejected by CreateSecret so they are never mistaken for files
+const secretFilePrefix = "locker-file:v1:"
+const secretFileEncoding = ";base64,"
+
+type SecretFileOptions struct {
+	Desc *string
+	Env  *string
+	// ContentType is recorded with the data, defaults to application/octet-stream
+	ContentType string
+	// MaxSize in bytes, defaults to DefaultMaxSecretFileSize
+	MaxSize int
+}
+
+// CreateSecretFile stores the content of r (certificates, keystores, ...) as key, base64 encoded behind a marker
+// that GetSecretBytes recognizes
+func (locker *Locker) CreateSecretFile(key string, r io.Reader, opts *SecretFileOptions) (types.EncryptedSecResponse, error) {
+	if opts == nil {
+		opts = &SecretFileOptions{}
+	}
+
+	maxSize := opts.MaxSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSecretFileSize
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, int64(maxSize)+1))
+	if err != nil {
+		types.CURRENT_ERR = types.ERR_FILE
+		return types.EncryptedSecResponse{}, fmt.Errorf("error reading secret file: %w", err)
+	}
+	if len(data) > maxSize {
+		types.CURRENT_ERR = types.ERR_INPUT
+		return types.EncryptedSecResponse{}, fmt.Errorf("secret file exceeds the %d bytes limit", maxSize)
+	}
+
+	value, err := EncodeSecretFile(data, opts.ContentType)
+	if err != nil {
+		return types.EncryptedSecResponse{}, err
+	}
+
+	// the raw content is what ends up in files and logs, not its encoded form
+	locker.rememberValue(string(data))
+	return locker.CreateSecret(&InputSecData{Key: &key, Value: &value, Desc: copyString(opts.Desc), Env: copyString(opts.Env)})
+}
+
+// EncodeSecretFile encodes data the way CreateSecretFile stores it, for use with UpdateSecret
+func EncodeSecretFile(data []byte, contentType string) (string, error) {
+	if contentType == "" {
+		contentType = defaultSecretFileType
+	}
+	if strings.ContainsAny(contentType, ",;") {
+		types.CURRENT_ERR = types.ERR_INPUT
+		return "", fmt.Errorf("invalid content type %q", contentType)
+	}
+
+	return secretFilePrefix + contentType + secretFileEncoding + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// DecodeSecretFile returns the data and content type of a value written by EncodeSecretFile, other values
+// are returned as-is with an empty content type. A value carrying the marker must be well-formed.
+func DecodeSecretFile(value string) ([]byte, string, error) {
+	if !strings.HasPrefix(value, secretFilePrefix) {
+		return []byte(value), "", nil
+	}
+
+	contentType, payload, ok := strings.Cut(strings.TrimPrefix(value, secretFilePrefix), secretFileEncoding)
+	if !ok || contentType == "" || strings.ContainsAny(contentType, ",;") {
+		types.CURRENT_ERR = types.ERR_DATA
+		return nil, "", fmt.Errorf("error decoding secret file: malformed header")
+	}
+
+	data, err := base64.StdEncoding.Strict().DecodeString(payload)
+	if err != nil {
+		types.CURRENT_ERR = types.ERR_DATA
+		return nil, "", fmt.Errorf("error decoding secret file: %w", err)
+	}
+
+	return data, contentType, nil
+}
+
+// checkSecretFileMarker rejects values carrying the file marker without being a valid file encoding
+func checkSecretFileMarker(value string) error {
+	_, _, err := DecodeSecretFile(value)
+	if err != nil {
+		types.CURRENT_ERR = types.ERR_INPUT
+		return fmt.Errorf("value starts with the reserved %q marker: %w", secretFilePrefix, err)
+	}
+	return nil
+}
+
+// GetSecretBytes returns the raw content of a secret created with CreateSecretFile, plain values are returned as bytes
+func (locker *Locker) GetSecretBytes(key string, env *string) ([]byte, error) {
+	secObj, err := locker.GetSecret(key, env)
+	if err != nil {
+		return nil, err
+	}
+
+	data, _, err := DecodeSecretFile(secObj.Value)
+	if err != nil {
+		return nil, err
+	}
+	locker.rememberValue(string(data))
+	return data, nil
+}
+
+// MaterializeSecretFile writes the content of a secret to a new file in dir and returns its path with a cleanup
+// function removing it. dir defaults to /dev/shm when available so the data stays in memory, then to the
+// temporary directory; perm defaults to 0600.
+func (locker *Locker) MaterializeSecretFile(key string, env *string, dir string, perm os.FileMode) (string, func() error, error) {
+	if perm == 0 {
+		perm = 0600
+	}
+	if dir == "" {
+		dir = secretFileDir()
+	}
+
+	data, err := locker.GetSecretBytes(key, env)
+	if err != nil {
+		return "", nil, err
+	}
+
+	file, err := os.CreateTemp(dir, "locker-secret-*")
+	if err != nil {
+		types.CURRENT_ERR = types.ERR_FILE
+		return "", nil, fmt.Errorf("error creating secret file: %w", err)
+	}
+	filePath := file.Name()
+
+	cleanup := func() error {
+		err := os.Remove(filePath)
+		if err != nil && !os.IsNotExist(err) {
+			types.CURRENT_ERR = types.ERR_FILE
+			return fmt.Errorf("error removing secret file: %w", err)
+		}
+		return nil
+	}
+
+	err = file.Chmod(perm)
+	if err == nil {
+		_, err = file.Write(data)
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = cleanup()
+		types.CURRENT_ERR = types.ERR_FILE
+		return "", nil, fmt.Errorf("error writing secret file: %w", err)
+	}
+
+	return filePath, cleanup, nil
+}
+
+func secretFileDir() string {
+	info, err := os.Stat("/dev/shm")
+	if err == nil && info.IsDir() {
+		return "/dev/shm"
+	}
+	return os.TempDir()
+}
@@ -0,0 +1,140 @@
+package locker
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/lockerpm/secrets-sdk-go/types"
+)
+
+func TestSecretFileEncoding(t *testing.T) {
+	data := []byte{0x00, 0xff, 'P', 'K', 0x03, 0x04, '\n'}
+	value, err := EncodeSecretFile(data, "application/x-pkcs12")
+	if err != nil {
+		t.Fatalf("encode secret file broke, error: %v", err)
+	}
+
+	decoded, contentType, err := DecodeSecretFile(value)
+	if err != nil {
+		t.Fatalf("decode secret file broke, error: %v", err)
+	}
+	if !bytes.Equal(decoded, data) || contentType != "application/x-pkcs12" {
+		t.Fatalf("secret file round trip broke, getting %v (%s)", decoded, contentType)
+	}
+}
+
+func TestDecodeSecretFilePlainValues(t *testing.T) {
+	// values that only look like encoded data are returned as-is
+	for _, value := range []string{
+		"plain",
+		"data:text/plain;base64,aGVsbG8=",
+		"data:source=db01;user=app",
+		"locker-file:v0:text/plain;base64,aGVsbG8=",
+	} {
+		decoded, contentType, err := DecodeSecretFile(value)
+		if err != nil {
+			t.Fatalf("decode secret file broke for %q, error: %v", value, err)
+		}
+		if string(decoded) != value || contentType != "" {
+			t.Fatalf("decode secret file broke, %q should be returned as-is", value)
+		}
+		if checkSecretFileMarker(value) != nil {
+			t.Fatalf("secret file marker check broke, %q should be accepted", value)
+		}
+	}
+}
+
+func TestDecodeSecretFileMalformed(t *testing.T) {
+	for _, value := range []string{
+		"locker-file:v1:",
+		"locker-file:v1:text/plain",
+		"locker-file:v1:;base64,aGVsbG8=",
+		"locker-file:v1:text/plain;charset=utf-8;base64,aGVsbG8=",
+		"locker-file:v1:text/plain;base64,not base64",
+		"locker-file:v1:text/plain;base64,aGVsbG8",
+	} {
+		_, _, err := DecodeSecretFile(value)
+		if err == nil {
+			t.Fatalf("decode secret file broke, expecting an error for %q", value)
+		}
+		if checkSecretFileMarker(value) == nil {
+			t.Fatalf("secret file marker check broke, %q should be rejected", value)
+		}
+	}
+}
+
+func TestEncodeSecretFileInvalidContentType(t *testing.T) {
+	_, err := EncodeSecretFile([]byte("x"), "text/plain; charset=utf-8")
+	if err == nil {
+		t.Fatalf("encode secret file broke, expecting an error for a content type with parameters")
+	}
+}
+
+func TestCreateSecretFile(t *testing.T) {
+	server, client := newTestServer(t)
+	server.addEnvironment(t, "production")
+
+	data := []byte{0x00, 0xff, 'P', 'K', 0x03, 0x04, '\n'}
+	desc, env := "client certificate", "production"
+	opts := &SecretFileOptions{ContentType: "application/x-pkcs12", Desc: &desc, Env: &env}
+	_, err := client.CreateSecretFile("CLIENT_CERT", bytes.NewReader(data), opts)
+	if err != nil {
+		t.Fatalf("create secret file broke, error: %v", err)
+	}
+	if desc != "client certificate" || env != "production" || *opts.Desc != desc || *opts.Env != env {
+		t.Fatalf("create secret file broke, the caller's options were modified: %q, %q", desc, env)
+	}
+
+	stored, ok := server.findSecret("CLIENT_CERT", "production")
+	if !ok || server.decrypt(t, stored.Description) != desc {
+		t.Fatalf("create secret file broke, the server holds %+v", stored)
+	}
+
+	got, err := client.GetSecretBytes("CLIENT_CERT", &env)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("get secret bytes broke, expecting %v, getting %v (%v)", data, got, err)
+	}
+
+	filePath, cleanup, err := client.MaterializeSecretFile("CLIENT_CERT", &env, t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("materialize secret file broke, error: %v", err)
+	}
+	content, err := os.ReadFile(filePath)
+	if err != nil || !bytes.Equal(content, data) {
+		t.Fatalf("materialize secret file broke, expecting %v, getting %v (%v)", data, content, err)
+	}
+	info, err := os.Stat(filePath)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("materialize secret file broke, expecting mode 0600, getting %v (%v)", info.Mode().Perm(), err)
+	}
+	err = cleanup()
+	if err != nil {
+		t.Fatalf("secret file cleanup broke, error: %v", err)
+	}
+	if _, err := os.Stat(filePath); !os.IsNotExist(err) {
+		t.Fatalf("secret file cleanup broke, the file still exists")
+	}
+	if err := cleanup(); err != nil {
+		t.Fatalf("secret file cleanup broke, a second cleanup must not fail, error: %v", err)
+	}
+
+	// plain values are returned as bytes
+	server.addSecret(t, "PLAIN", "plain-value", "")
+	got, err = client.GetSecretBytes("PLAIN", nil)
+	if err != nil || string(got) != "plain-value" {
+		t.Fatalf("get secret bytes broke for a plain value, getting %q (%v)", got, err)
+	}
+}
+
+func TestCreateSecretFileTooLarge(t *testing.T) {
+	server, client := newTestServer(t)
+
+	_, err := client.CreateSecretFile("BIG", bytes.NewReader(make([]byte, 11)), &SecretFileOptions{MaxSize: 10})
+	if err == nil || types.CURRENT_ERR != types.ERR_INPUT {
+		t.Fatalf("create secret file broke, expecting a size error, getting %v", err)
+	}
+	if calls := server.calls(); len(calls) != 0 {
+		t.Fatalf("create secret file broke, nothing must be sent, getting %v", calls)
+	}
+}
@@ -0,0 +1,131 @@
+package locker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/lockerpm/secrets-sdk-go/types"
+)
+
+func TestInputSecDataRedacted(t *testing.T) {
+	key, value, env := "DB_PASSWORD", "plain-s3cr3t-value", "production"
+	input := InputSecData{Key: &key, Value: &value, Env: &env}
+
+	for _, verb := range []string{"%v", "%+v", "%#v", "%s"} {
+		for _, formatted := range []interface{}{input, &input} {
+			output := fmt.Sprintf(verb, formatted)
+			if strings.Contains(output, value) {
+				t.Fatalf("input formatting broke, %s leaks the value: %s", verb, output)
+			}
+		}
+	}
+	if output := fmt.Sprintf("%+v", input); !strings.Contains(output, "Key:DB_PASSWORD") || !strings.Contains(output, "Value:"+types.REDACTED) {
+		t.Fatalf("input formatting broke, pointers must be printed as values, getting %s", output)
+	}
+
+	data, err := json.Marshal(input)
+	if err != nil {
+		t.Fatalf("input json encoding broke, error: %v", err)
+	}
+	if strings.Contains(string(data), value) || !strings.Contains(string(data), types.REDACTED) {
+		t.Fatalf("input json encoding broke, getting %s", data)
+	}
+
+	var output bytes.Buffer
+	slog.New(slog.NewJSONHandler(&output, nil)).Info("message", "input", input)
+	if strings.Contains(output.String(), value) || !strings.Contains(output.String(), `"key":"DB_PASSWORD"`) {
+		t.Fatalf("input logging broke, getting %s", output.String())
+	}
+
+	data, err = types.MarshalRevealed(&input)
+	if err != nil {
+		t.Fatalf("input revealed encoding broke, error: %v", err)
+	}
+	if !strings.Contains(string(data), `"value":"`+value+`"`) {
+		t.Fatalf("input revealed encoding broke, getting %s", data)
+	}
+}
+
+func stringPointer(value string) *string {
+	return &value
+}
+
+// requestValue decodes the value field sent in a request body
+func requestValue(t *testing.T, body []byte) string {
+	t.Helper()
+
+	var request map[string]interface{}
+	err := json.Unmarshal(body, &request)
+	if err != nil {
+		t.Fatalf("request body broke, error: %v (%s)", err, body)
+	}
+	value, _ := request["value"].(string)
+	return value
+}
+
+func TestSecretRequestBodiesRevealed(t *testing.T) {
+	server, client := newTestServer(t)
+	server.addEnvironment(t, "production")
+
+	// the input fields are encrypted in place, the plaintext is kept apart
+	const value, newValue = "plain-s3cr3t-value", "updated-s3cr3t-value"
+	key, env := "DB_PASSWORD", "production"
+	_, err := client.CreateSecret(&InputSecData{Key: copyString(&key), Value: stringPointer(value), Env: copyString(&env)})
+	if err != nil {
+		t.Fatalf("create secret broke, error: %v", err)
+	}
+
+	body := server.lastBody(http.MethodPost)
+	if bytes.Contains(body, []byte(types.REDACTED)) || bytes.Contains(body, []byte(value)) {
+		t.Fatalf("create secret broke, the body must hold the encrypted value, getting %s", body)
+	}
+	if sent := server.decrypt(t, requestValue(t, body)); sent != value {
+		t.Fatalf("create secret broke, expecting %q sent, getting %q instead", value, sent)
+	}
+
+	_, err = client.UpdateSecret(key, &env, &InputSecData{Value: stringPointer(newValue)})
+	if err != nil {
+		t.Fatalf("update secret broke, error: %v", err)
+	}
+
+	body = server.lastBody(http.MethodPut)
+	if bytes.Contains(body, []byte(types.REDACTED)) || bytes.Contains(body, []byte(newValue)) {
+		t.Fatalf("update secret broke, the body must hold the encrypted value, getting %s", body)
+	}
+	if sent := server.decrypt(t, requestValue(t, body)); sent != newValue {
+		t.Fatalf("update secret broke, expecting %q sent, getting %q instead", newValue, sent)
+	}
+
+	stored, ok := server.findSecret(key, env)
+	if !ok || server.decrypt(t, stored.Value) != newValue {
+		t.Fatalf("update secret broke, the server holds %+v", stored)
+	}
+
+	got, err := client.GetSecret(key, &env)
+	if err != nil || got.Value != newValue {
+		t.Fatalf("get secret broke, expecting %q, getting %q (%v)", newValue, got.Value, err)
+	}
+}
+
+func TestSecretDryRunFields(t *testing.T) {
+	server, client := newTestServer(t)
+	client.DryRun = true
+
+	_, err := client.CreateSecret(&InputSecData{Key: stringPointer("API_TOKEN"), Value: stringPointer("plain-s3cr3t-value")})
+	if err != nil {
+		t.Fatalf("dry run create secret broke, error: %v", err)
+	}
+
+	plan := client.DryRunPlan()
+	if len(plan) != 1 || strings.Join(plan[0].Fields, ",") != "hash,key,value" {
+		t.Fatalf("dry run create secret broke, the planned body must be the revealed one, getting %+v", plan)
+	}
+	if calls := server.calls(); len(calls) != 0 {
+		t.Fatalf("dry run create secret broke, nothing must be sent, getting %v", calls)
+	}
+}
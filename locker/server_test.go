@@ -0,0 +1,577 @@
+package locker
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/lockerpm/secrets-sdk-go/types"
+)
+
+// testServer is an in-memory Locker API, serving the endpoints used by the client
+type testServer struct {
+	*httptest.Server
+
+	mutex        sync.Mutex
+	projectID    int
+	profileKey   string
+	symKey       []byte
+	macKey       []byte
+	nextID       int
+	revisionDate float64
+	deletionDate float64
+	secrets      map[string]types.Secret
+	environments map[string]types.Environment
+	requests     []testRequest
+	// fail, when set, may answer a create, update or delete call with an error status and message
+	fail func(method, path string, body []byte) (int, string)
+	// failAfter is like fail, but the call is applied before the error is returned
+	failAfter func(method, path string, body []byte) (int, string)
+}
+
+type testRequest struct {
+	Method string
+	Path   string
+	Body   []byte
+}
+
+func newTestServer(t *testing.T) (*testServer, *Locker) {
+	t.Helper()
+
+	accessKey := mustRandomBytes(t, 32)
+	server := &testServer{
+		projectID:    42,
+		symKey:       mustRandomBytes(t, 32),
+		macKey:       mustRandomBytes(t, 32),
+		revisionDate: 1,
+		secrets:      map[string]types.Secret{},
+		environments: map[string]types.Environment{},
+	}
+
+	stretchedKey, stretchedMacKey, err := generateKey(accessKey)
+	if err != nil {
+		t.Fatalf("test server broke, error: %v", err)
+	}
+	server.profileKey, err = aes256EncryptToString(append(append([]byte{}, server.symKey...), server.macKey...), stretchedKey, stretchedMacKey)
+	if err != nil {
+		t.Fatalf("test server broke, error: %v", err)
+	}
+
+	server.Server = httptest.NewServer(http.HandlerFunc(server.handle))
+	t.Cleanup(server.Close)
+
+	client := &Locker{
+		SecretAccessKey: base64.StdEncoding.EncodeToString(accessKey),
+		APIBase:         server.URL,
+		WorkingDir:      t.TempDir(),
+		Headers:         map[string]string{},
+		Fetch:           true,
+	}
+	client.SetAccessKeyID(fmt.Sprintf("test-%x", mustRandomBytes(t, 8)))
+	t.Cleanup(func() {
+		sqlDB, err := client.dBConn.DB()
+		if err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	return server, client
+}
+
+func mustRandomBytes(t *testing.T, size int) []byte {
+	t.Helper()
+
+	data, err := randomBytes(size)
+	if err != nil {
+		t.Fatalf("reading random bytes broke, error: %v", err)
+	}
+	return data
+}
+
+// hash matches Locker.getHash for the server project
+func (server *testServer) hash(plain string) string {
+	sum := sha256.Sum256([]byte(strconv.Itoa(server.projectID) + plain))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// encrypt encrypts value with the project keys, like the client does before sending it
+func (server *testServer) encrypt(t *testing.T, value string) string {
+	t.Helper()
+
+	encrypted, err := aes256EncryptToString([]byte(value), server.symKey, server.macKey)
+	if err != nil {
+		t.Fatalf("test server encryption broke, error: %v", err)
+	}
+	return encrypted
+}
+
+// decrypt decrypts a value sent by the client
+func (server *testServer) decrypt(t *testing.T, value string) string {
+	t.Helper()
+
+	decrypted, err := aes256DecryptToString(value, server.symKey, server.macKey)
+	if err != nil {
+		t.Fatalf("test server decryption of %q broke, error: %v", value, err)
+	}
+	return decrypted
+}
+
+// addEnvironment stores an environment directly on the server
+func (server *testServer) addEnvironment(t *testing.T, name string) types.Environment {
+	t.Helper()
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.nextID++
+	server.revisionDate++
+	env := types.Environment{
+		Object:       "environment",
+		ID:           fmt.Sprintf("env-%d", server.nextID),
+		Name:         server.encrypt(t, name),
+		Hash:         server.hash(name),
+		CreationDate: server.revisionDate,
+		RevisionDate: server.revisionDate,
+		ProjectID:    server.projectID,
+	}
+	server.environments[env.ID] = env
+	return env
+}
+
+// addSecret stores a secret directly on the server, in env when not empty
+func (server *testServer) addSecret(t *testing.T, key, value, env string) types.Secret {
+	t.Helper()
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.nextID++
+	server.revisionDate++
+	secret := types.Secret{
+		Object:       "secret",
+		ID:           fmt.Sprintf("sec-%d", server.nextID),
+		CreationDate: server.revisionDate,
+		RevisionDate: server.revisionDate,
+		ProjectID:    server.projectID,
+		Key:          server.encrypt(t, key),
+		SecretHash:   server.hash(key),
+		Value:        server.encrypt(t, value),
+		Description:  server.encrypt(t, ""),
+	}
+	if env != "" {
+		envHash := server.hash(env)
+		for _, envObj := range server.environments {
+			if envObj.Hash == envHash {
+				secret.EnvironmentID = &envObj.ID
+				secret.EnvironmentName = &envObj.Name
+				secret.EnvironmentHash = &envHash
+			}
+		}
+		if secret.EnvironmentID == nil {
+			t.Fatalf("test server broke, no environment %q", env)
+		}
+	}
+	server.secrets[secret.ID] = secret
+	return secret
+}
+
+// describeSecret sets the description of a stored secret
+func (server *testServer) describeSecret(t *testing.T, ID, desc string) {
+	t.Helper()
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	secret := server.secrets[ID]
+	secret.Description = server.encrypt(t, desc)
+	server.revisionDate++
+	secret.RevisionDate = server.revisionDate
+	server.secrets[ID] = secret
+}
+
+// useSecret sets the last use date of a stored secret
+func (server *testServer) useSecret(ID string, lastUseDate float64) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	secret := server.secrets[ID]
+	secret.LastUseDate = &lastUseDate
+	server.revisionDate++
+	server.secrets[ID] = secret
+}
+
+// removeSecret deletes a stored secret like another client would
+func (server *testServer) removeSecret(ID string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	delete(server.secrets, ID)
+	server.revisionDate++
+	server.deletionDate = server.revisionDate
+}
+
+// findSecret returns the stored secret key of env (ALL when empty)
+func (server *testServer) findSecret(key, env string) (types.Secret, bool) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	secretHash := server.hash(key)
+	for _, secret := range server.secrets {
+		if secret.SecretHash != secretHash {
+			continue
+		}
+		if env == "" && secret.EnvironmentHash == nil {
+			return secret, true
+		}
+		if env != "" && secret.EnvironmentHash != nil && *secret.EnvironmentHash == server.hash(env) {
+			return secret, true
+		}
+	}
+	return types.Secret{}, false
+}
+
+// calls returns the create, update and delete requests received so far, as "METHOD path"
+func (server *testServer) calls() []string {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	var calls []string
+	for _, request := range server.requests {
+		if request.Method != http.MethodGet {
+			calls = append(calls, request.Method+" "+request.Path)
+		}
+	}
+	return calls
+}
+
+// lastBody returns the body of the last request of method
+func (server *testServer) lastBody(method string) []byte {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	for i := len(server.requests) - 1; i >= 0; i-- {
+		if server.requests[i].Method == method {
+			return server.requests[i].Body
+		}
+	}
+	return nil
+}
+
+func (server *testServer) handle(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.requests = append(server.requests, testRequest{Method: r.Method, Path: r.URL.Path, Body: body})
+
+	if r.Method != http.MethodGet && server.fail != nil {
+		if status, message := server.fail(r.Method, r.URL.Path, body); status != 0 {
+			writeTestJSON(w, status, types.ServerErrorMsg{Message: message})
+			return
+		}
+	}
+
+	if r.Method != http.MethodGet && server.failAfter != nil {
+		if status, message := server.failAfter(r.Method, r.URL.Path, body); status != 0 {
+			server.route(httptest.NewRecorder(), r, body)
+			writeTestJSON(w, status, types.ServerErrorMsg{Message: message})
+			return
+		}
+	}
+
+	server.route(w, r, body)
+}
+
+func (server *testServer) route(w http.ResponseWriter, r *http.Request, body []byte) {
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.URL.Path == "/v1/sync/revision_date":
+		fmt.Fprintf(w, "%f", server.revisionDate)
+	case r.URL.Path == "/v1/sync/deleted_item_date":
+		fmt.Fprintf(w, "%f", server.deletionDate)
+	case r.URL.Path == "/v1/sync/secrets/count":
+		fmt.Fprintf(w, "%d", len(server.secrets))
+	case r.URL.Path == "/v1/sync/environments/count":
+		fmt.Fprintf(w, "%d", len(server.environments))
+	case r.URL.Path == "/v1/profile":
+		var profile types.ProfileResponse
+		profile.Object = "profile"
+		profile.Profile.ID = "profile-1"
+		profile.Profile.Key = server.profileKey
+		profile.Profile.ProjectID = server.projectID
+		profile.Profile.Activated = true
+		writeTestJSON(w, http.StatusOK, profile)
+	case len(segments) >= 2 && segments[1] == types.FETCH_KIND_SEC:
+		server.handleSecrets(w, r, segments[2:], body)
+	case len(segments) >= 2 && segments[1] == types.FETCH_KIND_ENV:
+		server.handleEnvironments(w, r, segments[2:], body)
+	default:
+		writeTestJSON(w, http.StatusNotFound, types.ServerErrorMsg{Message: "not found"})
+	}
+}
+
+func (server *testServer) handleSecrets(w http.ResponseWriter, r *http.Request, segments []string, body []byte) {
+	var input struct {
+		Key   *string `json:"key"`
+		Hash  string  `json:"hash"`
+		Value *string `json:"value"`
+		Desc  *string `json:"description"`
+		EnvID *string `json:"environment_id"`
+	}
+	if len(body) > 0 {
+		err := json.Unmarshal(body, &input)
+		if err != nil {
+			writeTestJSON(w, http.StatusBadRequest, types.ServerErrorMsg{Message: "invalid body"})
+			return
+		}
+	}
+
+	if len(segments) == 0 {
+		switch r.Method {
+		case http.MethodGet:
+			hash, envID := r.URL.Query().Get("hash"), r.URL.Query().Get("environment_id")
+			var results []types.Secret
+			for _, secret := range server.secrets {
+				if hash != "" && secret.SecretHash != hash {
+					continue
+				}
+				if envID != "" && (secret.EnvironmentID == nil || *secret.EnvironmentID != envID) {
+					continue
+				}
+				results = append(results, secret)
+			}
+			writeTestJSON(w, http.StatusOK, secretListResponse(results, server.revisionDate))
+		case http.MethodPost:
+			server.nextID++
+			secret := types.Secret{
+				Object:       "secret",
+				ID:           fmt.Sprintf("sec-%d", server.nextID),
+				CreationDate: server.revisionDate + 1,
+				ProjectID:    server.projectID,
+			}
+			if !server.applySecret(w, &secret, input.Key, input.Hash, input.Value, input.Desc, input.EnvID) {
+				return
+			}
+			writeTestJSON(w, http.StatusCreated, secretResponse(secret))
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	secret, ok := server.secrets[segments[0]]
+	if !ok || len(segments) > 1 {
+		writeTestJSON(w, http.StatusNotFound, types.ServerErrorMsg{Message: "not found"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		if !server.applySecret(w, &secret, input.Key, input.Hash, input.Value, input.Desc, input.EnvID) {
+			return
+		}
+		writeTestJSON(w, http.StatusOK, secretResponse(secret))
+	case http.MethodDelete:
+		delete(server.secrets, secret.ID)
+		server.revisionDate++
+		server.deletionDate = server.revisionDate
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// applySecret sets the fields sent by the client on secret and stores it, rejecting duplicated hashes
+func (server *testServer) applySecret(w http.ResponseWriter, secret *types.Secret, key *string, hash string, value, desc, envID *string) bool {
+	if key != nil {
+		secret.Key = *key
+	}
+	if hash != "" {
+		secret.SecretHash = hash
+	}
+	if value != nil {
+		secret.Value = *value
+	}
+	if desc != nil {
+		secret.Description = *desc
+	}
+	if envID != nil {
+		secret.EnvironmentID, secret.EnvironmentName, secret.EnvironmentHash = nil, nil, nil
+		if *envID != "" {
+			env, ok := server.environments[*envID]
+			if !ok {
+				writeTestJSON(w, http.StatusBadRequest, types.ServerErrorMsg{Message: "environment does not exist"})
+				return false
+			}
+			secret.EnvironmentID, secret.EnvironmentName, secret.EnvironmentHash = &env.ID, &env.Name, &env.Hash
+		}
+	}
+
+	for _, other := range server.secrets {
+		if other.ID == secret.ID || other.SecretHash != secret.SecretHash {
+			continue
+		}
+		if derefString(other.EnvironmentHash) == derefString(secret.EnvironmentHash) {
+			writeTestJSON(w, http.StatusBadRequest, types.ServerErrorMsg{Message: types.SERVER_ERR_MSG_DUP})
+			return false
+		}
+	}
+
+	server.revisionDate++
+	secret.RevisionDate = server.revisionDate
+	server.secrets[secret.ID] = *secret
+	return true
+}
+
+// secretListResponse is a types.SecretResponse, with the values revealed like the API sends them
+func secretListResponse(results []types.Secret, revisionDate float64) interface{} {
+	revealed := make([]interface{}, 0, len(results))
+	for _, secret := range results {
+		revealed = append(revealed, secret.RevealedJSON())
+	}
+	return struct {
+		Count        int           `json:"count"`
+		Next         string        `json:"next"`
+		RevisionDate float64       `json:"revision_date"`
+		Results      []interface{} `json:"results"`
+	}{Count: len(results), RevisionDate: revisionDate, Results: revealed}
+}
+
+func secretResponse(secret types.Secret) types.EncryptedSecResponse {
+	return types.EncryptedSecResponse{
+		Object:          secret.Object,
+		ID:              secret.ID,
+		CreationDate:    secret.CreationDate,
+		RevisionDate:    secret.RevisionDate,
+		ProjectID:       secret.ProjectID,
+		Key:             secret.Key,
+		SecretHash:      secret.SecretHash,
+		Value:           secret.Value,
+		Description:     secret.Description,
+		EnvironmentID:   secret.EnvironmentID,
+		EnvironmentName: secret.EnvironmentName,
+		EnvironmentHash: secret.EnvironmentHash,
+	}
+}
+
+func (server *testServer) handleEnvironments(w http.ResponseWriter, r *http.Request, segments []string, body []byte) {
+	var input InputEnvData
+	if len(body) > 0 {
+		err := json.Unmarshal(body, &input)
+		if err != nil {
+			writeTestJSON(w, http.StatusBadRequest, types.ServerErrorMsg{Message: "invalid body"})
+			return
+		}
+	}
+
+	if len(segments) == 0 {
+		switch r.Method {
+		case http.MethodGet:
+			hash := r.URL.Query().Get("hash")
+			results := []types.Environment{}
+			for _, env := range server.environments {
+				if hash == "" || env.Hash == hash {
+					results = append(results, env)
+				}
+			}
+			writeTestJSON(w, http.StatusOK, types.EnvironmentResponse{Count: len(results), RevisionDate: server.revisionDate, Results: results})
+		case http.MethodPost:
+			server.nextID++
+			env := types.Environment{
+				Object:       "environment",
+				ID:           fmt.Sprintf("env-%d", server.nextID),
+				CreationDate: server.revisionDate + 1,
+				ProjectID:    server.projectID,
+			}
+			if !server.applyEnvironment(w, &env, input) {
+				return
+			}
+			writeTestJSON(w, http.StatusCreated, environmentResponse(env))
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	env, ok := server.environments[segments[0]]
+	if !ok || len(segments) > 1 {
+		writeTestJSON(w, http.StatusNotFound, types.ServerErrorMsg{Message: "not found"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		if !server.applyEnvironment(w, &env, input) {
+			return
+		}
+		writeTestJSON(w, http.StatusOK, environmentResponse(env))
+	case http.MethodDelete:
+		delete(server.environments, env.ID)
+		for ID, secret := range server.secrets {
+			if secret.EnvironmentID != nil && *secret.EnvironmentID == env.ID {
+				delete(server.secrets, ID)
+			}
+		}
+		server.revisionDate++
+		server.deletionDate = server.revisionDate
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (server *testServer) applyEnvironment(w http.ResponseWriter, env *types.Environment, input InputEnvData) bool {
+	if input.Name != nil {
+		env.Name = *input.Name
+	}
+	if input.Hash != "" {
+		env.Hash = input.Hash
+	}
+	if input.Url != nil {
+		env.ExternalURL = *input.Url
+	}
+	if input.Desc != nil {
+		env.Description = *input.Desc
+	}
+
+	for _, other := range server.environments {
+		if other.ID != env.ID && other.Hash == env.Hash {
+			writeTestJSON(w, http.StatusBadRequest, types.ServerErrorMsg{Message: types.SERVER_ERR_MSG_DUP})
+			return false
+		}
+	}
+
+	server.revisionDate++
+	env.RevisionDate = server.revisionDate
+	server.environments[env.ID] = *env
+	return true
+}
+
+func environmentResponse(env types.Environment) types.EncryptedEnvResponse {
+	return types.EncryptedEnvResponse{
+		Object:       env.Object,
+		ID:           env.ID,
+		CreationDate: env.CreationDate,
+		RevisionDate: env.RevisionDate,
+		Name:         env.Name,
+		Hash:         env.Hash,
+		ExternalURL:  env.ExternalURL,
+		Description:  env.Description,
+		ProjectID:    env.ProjectID,
+	}
+}
+
+func writeTestJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = types.NewRevealingEncoder(w).Encode(body)
+}
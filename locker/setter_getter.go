@@ -114,3 +114,11 @@ func (locker *Locker) GetGettingFromLocal() bool {
 func (locker *Locker) SetGettingFromLocal(gettingFromLocal bool) {
 	locker.GettingFromLocal = gettingFromLocal
 }
+
+func (locker *Locker) GetDryRun() bool {
+	return locker.DryRun
+}
+
+func (locker *Locker) SetDryRun(dryRun bool) {
+	locker.DryRun = dryRun
+}
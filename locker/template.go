@@ -0,0 +1,140 @@
+package locker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"text/template"
+
+	"github.com/lockerpm/secrets-sdk-go/types"
+)
+
+type TemplateOptions struct {
+	// Name of the template in error messages, defaults to "locker"
+	Name string
+	// AllowMissing renders missing secrets as empty strings instead of failing, to be combined with default
+	AllowMissing bool
+	// Funcs are added to (and may override) the secret functions
+	Funcs template.FuncMap
+	// Data is passed to the template as "."
+	Data interface{}
+}
+
+// RenderTemplate renders the text/template tmpl to w with the functions of TemplateFuncs, secrets come from env
+// (falling back to ALL). Nothing is written when rendering fails.
+func (locker *Locker) RenderTemplate(w io.Writer, tmpl string, env *string, opts *TemplateOptions) error {
+	if opts == nil {
+		opts = &TemplateOptions{}
+	}
+
+	name := opts.Name
+	if name == "" {
+		name = "locker"
+	}
+
+	parsed, err := template.New(name).Option("missingkey=error").Funcs(locker.TemplateFuncs(env, opts)).Funcs(opts.Funcs).Parse(tmpl)
+	if err != nil {
+		types.CURRENT_ERR = types.ERR_INPUT
+		return fmt.Errorf("error parsing template: %w", err)
+	}
+
+	// render in memory first so a failing template never leaves a partial config file behind
+	var buffer bytes.Buffer
+	err = parsed.Execute(&buffer, opts.Data)
+	if err != nil {
+		types.CURRENT_ERR = types.ERR_DATA
+		return fmt.Errorf("error rendering template: %w", err)
+	}
+
+	_, err = w.Write(buffer.Bytes())
+	return err
+}
+
+// TemplateFuncs returns the template functions used by RenderTemplate, for use in your own templates:
+//
+//	secret "KEY"            value of KEY in env (falling back to ALL)
+//	secretEnv "prod" "KEY"  value of KEY in another environment (falling back to ALL)
+//	secretAll "KEY"         value of KEY in ALL
+//	secretJSON "KEY"        value of KEY in env decoded as JSON, e.g. {{ (secretJSON "DB").host }}
+//	default "x" value       value, or "x" when value is empty
+//
+// Secrets are read once per environment, on first use. Only opts.AllowMissing is used.
+func (locker *Locker) TemplateFuncs(env *string, opts *TemplateOptions) template.FuncMap {
+	allowMissing := opts != nil && opts.AllowMissing
+	loaded := make(map[string]map[string]types.Secret)
+
+	lookup := func(envName *string, key string) (string, bool, error) {
+		// environment names are never empty, "" stands for ALL
+		cacheKey := ""
+		if envName != nil {
+			cacheKey = *envName
+		}
+
+		secrets, ok := loaded[cacheKey]
+		if !ok {
+			var err error
+			secrets, err = locker.listRunSecrets(envName)
+			if err != nil {
+				return "", false, err
+			}
+			loaded[cacheKey] = secrets
+		}
+
+		secObj, ok := secrets[key]
+		if !ok {
+			if allowMissing {
+				return "", false, nil
+			}
+			types.CURRENT_ERR = types.ERR_NOT_FOUND
+			return "", false, fmt.Errorf("no secret found with name %q", key)
+		}
+		return secObj.Value, true, nil
+	}
+
+	return template.FuncMap{
+		"secret": func(key string) (string, error) {
+			value, _, err := lookup(env, key)
+			return value, err
+		},
+		"secretEnv": func(envName, key string) (string, error) {
+			value, _, err := lookup(&envName, key)
+			return value, err
+		},
+		"secretAll": func(key string) (string, error) {
+			value, _, err := lookup(nil, key)
+			return value, err
+		},
+		"secretJSON": func(key string) (interface{}, error) {
+			value, found, err := lookup(env, key)
+			if err != nil || !found {
+				return nil, err
+			}
+
+			var decoded interface{}
+			err = json.Unmarshal([]byte(value), &decoded)
+			if err != nil {
+				types.CURRENT_ERR = types.ERR_DATA
+				return nil, fmt.Errorf("value of %q is not valid JSON", key)
+			}
+			return decoded, nil
+		},
+		"default": func(fallback, value interface{}) interface{} {
+			if value == nil {
+				return fallback
+			}
+			reflected := reflect.ValueOf(value)
+			if reflected.IsZero() {
+				return fallback
+			}
+			switch reflected.Kind() {
+			case reflect.Map, reflect.Slice, reflect.Array:
+				if reflected.Len() == 0 {
+					return fallback
+				}
+			}
+			return value
+		},
+	}
+}
@@ -0,0 +1,120 @@
+package locker
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/lockerpm/secrets-sdk-go/types"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	server, client := newTestServer(t)
+	server.addEnvironment(t, "production")
+	server.addEnvironment(t, "staging")
+	server.addSecret(t, "API_HOST", "api.example.com", "")
+	server.addSecret(t, "API_HOST", "api.production.example.com", "production")
+	server.addSecret(t, "API_HOST", "api.staging.example.com", "staging")
+	server.addSecret(t, "DB_CONFIG", `{"host": "db1", "port": 5432}`, "production")
+	server.addSecret(t, "NOT_JSON", "{", "")
+	server.addSecret(t, "EMPTY", "", "")
+
+	prod := "production"
+	tests := []struct {
+		name string
+		tmpl string
+		opts *TemplateOptions
+		want string
+	}{
+		{name: "secret", tmpl: `{{ secret "API_HOST" }}`, want: "api.production.example.com"},
+		{name: "secretEnv", tmpl: `{{ secretEnv "staging" "API_HOST" }}`, want: "api.staging.example.com"},
+		{name: "secretAll", tmpl: `{{ secretAll "API_HOST" }}`, want: "api.example.com"},
+		{name: "secretJSON", tmpl: `{{ (secretJSON "DB_CONFIG").host }}:{{ (secretJSON "DB_CONFIG").port }}`, want: "db1:5432"},
+		{name: "default", tmpl: `{{ secret "EMPTY" | default "8080" }}`, want: "8080"},
+		{name: "default of a value", tmpl: `{{ secret "API_HOST" | default "localhost" }}`, want: "api.production.example.com"},
+		{
+			name: "allow missing",
+			tmpl: `[{{ secret "MISSING" }}] {{ secret "MISSING" | default "fallback" }} {{ secretJSON "MISSING" | default "none" }}`,
+			opts: &TemplateOptions{AllowMissing: true},
+			want: "[] fallback none",
+		},
+		{
+			name: "funcs and data",
+			tmpl: `{{ upper (secret "API_HOST") }} {{ .Region }}`,
+			opts: &TemplateOptions{Funcs: template.FuncMap{"upper": strings.ToUpper}, Data: map[string]string{"Region": "eu"}},
+			want: "API.PRODUCTION.EXAMPLE.COM eu",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var output bytes.Buffer
+			err := client.RenderTemplate(&output, test.tmpl, &prod, test.opts)
+			if err != nil {
+				t.Fatalf("render template broke, error: %v", err)
+			}
+			if output.String() != test.want {
+				t.Fatalf("render template broke, expecting %q, getting %q instead", test.want, output.String())
+			}
+		})
+	}
+}
+
+func TestRenderTemplateFailures(t *testing.T) {
+	server, client := newTestServer(t)
+	server.addSecret(t, "API_HOST", "api.example.com", "")
+	server.addSecret(t, "NOT_JSON", "{", "")
+
+	tests := []struct {
+		name string
+		tmpl string
+		err  string
+		code string
+	}{
+		{name: "missing", tmpl: `{{ secret "API_HOST" }} {{ secret "MISSING" }}`, err: `no secret found with name "MISSING"`, code: types.ERR_DATA},
+		{name: "missing JSON", tmpl: `{{ secretJSON "MISSING" }}`, err: `no secret found with name "MISSING"`, code: types.ERR_DATA},
+		{name: "invalid JSON", tmpl: `{{ secretJSON "NOT_JSON" }}`, err: `value of "NOT_JSON" is not valid JSON`, code: types.ERR_DATA},
+		{name: "missing environment", tmpl: `{{ secretEnv "missing" "API_HOST" }}`, err: "no environment found", code: types.ERR_DATA},
+		{name: "missing data key", tmpl: `{{ .Region }}`, err: "Region", code: types.ERR_DATA},
+		{name: "parse", tmpl: `{{ secret "API_HOST" `, err: "error parsing template", code: types.ERR_INPUT},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var output bytes.Buffer
+			err := client.RenderTemplate(&output, test.tmpl, nil, &TemplateOptions{Data: map[string]string{}})
+			if err == nil || !strings.Contains(err.Error(), test.err) || types.CURRENT_ERR != test.code {
+				t.Fatalf("render template broke, expecting %q (%s), getting %v (%s)", test.err, test.code, err, types.CURRENT_ERR)
+			}
+			if output.Len() != 0 {
+				t.Fatalf("render template broke, nothing must be written on failure, getting %q", output.String())
+			}
+		})
+	}
+}
+
+func TestTemplateEnvironmentNamedALL(t *testing.T) {
+	server, client := newTestServer(t)
+	server.addSecret(t, "API_HOST", "api.example.com", "")
+
+	// ALL is an ordinary environment name, secretAll reads the shared secrets
+	name := types.ENV_ALL
+	_, err := client.CreateEnvironment(&InputEnvData{Name: copyString(&name)})
+	if err != nil {
+		t.Fatalf("create environment broke, error: %v", err)
+	}
+	_, err = client.CreateSecret(&InputSecData{Key: stringPointer("API_HOST"), Value: stringPointer("api.all-env.example.com"), Env: copyString(&name)})
+	if err != nil {
+		t.Fatalf("create secret broke, error: %v", err)
+	}
+
+	var output bytes.Buffer
+	err = client.RenderTemplate(&output, `{{ secretEnv "ALL" "API_HOST" }} {{ secretAll "API_HOST" }}`, nil, nil)
+	if err != nil {
+		t.Fatalf("render template broke, error: %v", err)
+	}
+	if want := "api.all-env.example.com api.example.com"; output.String() != want {
+		t.Fatalf("render template broke, expecting %q, getting %q instead", want, output.String())
+	}
+}
@@ -0,0 +1,132 @@
+package locker
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lockerpm/secrets-sdk-go/types"
+)
+
+const secondsPerDay = 24 * 60 * 60
+
+type UsageReportOptions struct {
+	// UnusedDays, when set, reports secrets not used for more than this many days, never used secrets are always reported
+	UnusedDays int
+	// RotationDays, when set, also reports secrets whose last revision is older than this many days
+	RotationDays int
+	// Env restricts the report to the secrets defined directly in one environment
+	Env *string
+	// Now is the reference time, defaults to time.Now()
+	Now time.Time
+}
+
+type UsageEntry struct {
+	Key          string   `json:"key"`
+	Environment  string   `json:"environment"`
+	LastUseDate  *float64 `json:"last_use_date"`
+	RevisionDate float64  `json:"revision_date"`
+	// DaysUnused is -1 for secrets that were never used
+	DaysUnused   int      `json:"days_unused"`
+	DaysRevision int      `json:"days_since_revision"`
+	Reasons      []string `json:"reasons"`
+}
+
+// UsageReport groups the reported secrets by environment name, types.ENV_ALL for secrets of ALL
+type UsageReport struct {
+	Environments map[string][]UsageEntry `json:"environments"`
+}
+
+// ExportEntries flattens the report as "environment/key" = "reasons" pairs, sorted
+func (report UsageReport) ExportEntries() []ExportEntry {
+	envNames := make([]string, 0, len(report.Environments))
+	for envName := range report.Environments {
+		envNames = append(envNames, envName)
+	}
+	sort.Strings(envNames)
+
+	var entries []ExportEntry
+	for _, envName := range envNames {
+		for _, usage := range report.Environments[envName] {
+			var details []string
+			for _, reason := range usage.Reasons {
+				switch reason {
+				case types.USAGE_NEVER_USED:
+					details = append(details, "never used")
+				case types.USAGE_UNUSED:
+					details = append(details, fmt.Sprintf("unused for %d days", usage.DaysUnused))
+				case types.USAGE_ROTATION_DUE:
+					details = append(details, fmt.Sprintf("not rotated for %d days", usage.DaysRevision))
+				}
+			}
+			entries = append(entries, ExportEntry{Key: envName + "/" + usage.Key, Value: strings.Join(details, ", ")})
+		}
+	}
+
+	return entries
+}
+
+// UsageReport lists the secrets that were never used, unused for more than opts.UnusedDays days and,
+// optionally, not rotated for more than opts.RotationDays days, based on their last_use_date and revision_date
+func (locker *Locker) UsageReport(opts *UsageReportOptions) (UsageReport, error) {
+	if opts == nil {
+		opts = &UsageReportOptions{}
+	}
+	if opts.UnusedDays < 0 || opts.RotationDays < 0 {
+		types.CURRENT_ERR = types.ERR_INPUT
+		return UsageReport{}, fmt.Errorf("day thresholds must not be negative")
+	}
+
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	nowSeconds := unixSeconds(now)
+
+	report := UsageReport{Environments: make(map[string][]UsageEntry)}
+	err := locker.IterSecrets(context.Background(), opts.Env, func(secObj types.Secret) error {
+		usage := UsageEntry{
+			Key:          secObj.Key,
+			Environment:  types.ENV_ALL,
+			LastUseDate:  secObj.LastUseDate,
+			RevisionDate: secObj.RevisionDate,
+			DaysUnused:   -1,
+			DaysRevision: int((nowSeconds - secObj.RevisionDate) / secondsPerDay),
+		}
+		if secObj.EnvironmentName != nil && *secObj.EnvironmentName != "" {
+			usage.Environment = *secObj.EnvironmentName
+		}
+
+		if secObj.LastUseDate == nil || *secObj.LastUseDate == 0 {
+			usage.Reasons = append(usage.Reasons, types.USAGE_NEVER_USED)
+		} else {
+			usage.DaysUnused = int((nowSeconds - *secObj.LastUseDate) / secondsPerDay)
+			if opts.UnusedDays > 0 && usage.DaysUnused > opts.UnusedDays {
+				usage.Reasons = append(usage.Reasons, types.USAGE_UNUSED)
+			}
+		}
+
+		if opts.RotationDays > 0 && usage.DaysRevision > opts.RotationDays {
+			usage.Reasons = append(usage.Reasons, types.USAGE_ROTATION_DUE)
+		}
+
+		if len(usage.Reasons) > 0 {
+			report.Environments[usage.Environment] = append(report.Environments[usage.Environment], usage)
+		}
+		return nil
+	})
+	if err != nil {
+		return UsageReport{}, err
+	}
+
+	for envName := range report.Environments {
+		usages := report.Environments[envName]
+		sort.Slice(usages, func(i, j int) bool {
+			return usages[i].Key < usages[j].Key
+		})
+	}
+
+	return report, nil
+}
@@ -0,0 +1,78 @@
+package locker
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lockerpm/secrets-sdk-go/types"
+)
+
+// usageReasons returns environment/key: reasons for each entry of report
+func usageReasons(report UsageReport) map[string][]string {
+	reasons := make(map[string][]string)
+	for envName, usages := range report.Environments {
+		for _, usage := range usages {
+			reasons[envName+"/"+usage.Key] = usage.Reasons
+		}
+	}
+	return reasons
+}
+
+func TestUsageReport(t *testing.T) {
+	server, client := newTestServer(t)
+	server.addEnvironment(t, "production")
+	server.addSecret(t, "NEVER_USED", "value", "")
+	stale := server.addSecret(t, "STALE", "value", "")
+	server.useSecret(stale.ID, 80*secondsPerDay)
+	fresh := server.addSecret(t, "FRESH", "value", "production")
+	server.useSecret(fresh.ID, 99*secondsPerDay)
+
+	// the fake server's revision dates are close to the epoch
+	now := time.Unix(100*secondsPerDay, 0)
+
+	report, err := client.UsageReport(&UsageReportOptions{UnusedDays: 10, Now: now})
+	if err != nil {
+		t.Fatalf("usage report broke, error: %v", err)
+	}
+	want := map[string][]string{
+		"ALL/NEVER_USED": {types.USAGE_NEVER_USED},
+		"ALL/STALE":      {types.USAGE_UNUSED},
+	}
+	if reasons := usageReasons(report); !reflect.DeepEqual(reasons, want) {
+		t.Fatalf("usage report broke, expecting %v, getting %v instead", want, reasons)
+	}
+	staleUsage := report.Environments[types.ENV_ALL][1]
+	if staleUsage.Key != "STALE" || staleUsage.DaysUnused != 20 || report.Environments[types.ENV_ALL][0].DaysUnused != -1 {
+		t.Fatalf("usage report broke, getting %+v", report.Environments[types.ENV_ALL])
+	}
+
+	report, err = client.UsageReport(&UsageReportOptions{UnusedDays: 10, RotationDays: 30, Now: now})
+	if err != nil {
+		t.Fatalf("usage report broke, error: %v", err)
+	}
+
+	var output bytes.Buffer
+	err = Export(&output, types.FORMAT_TEXT, report)
+	if err != nil {
+		t.Fatalf("usage report export broke, error: %v", err)
+	}
+	wantText := "ALL/NEVER_USED = never used, not rotated for 99 days\n" +
+		"ALL/STALE = unused for 20 days, not rotated for 99 days\n" +
+		"production/FRESH = not rotated for 99 days\n"
+	if output.String() != wantText {
+		t.Fatalf("usage report export broke, expecting\n%s\ngetting\n%s", wantText, output.String())
+	}
+
+	prod := "production"
+	report, err = client.UsageReport(&UsageReportOptions{RotationDays: 30, Env: &prod, Now: now})
+	if err != nil || !reflect.DeepEqual(usageReasons(report), map[string][]string{"production/FRESH": {types.USAGE_ROTATION_DUE}}) {
+		t.Fatalf("usage report broke for an environment, getting %v (%v)", usageReasons(report), err)
+	}
+
+	_, err = client.UsageReport(&UsageReportOptions{UnusedDays: -1})
+	if err == nil || types.CURRENT_ERR != types.ERR_INPUT {
+		t.Fatalf("usage report broke, expecting an error for a negative threshold, getting %v", err)
+	}
+}
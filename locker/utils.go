@@ -1,71 +1,99 @@
 package locker
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 
 	"github.com/lockerpm/secrets-sdk-go/types"
 )
 
+// ExportOutput writes result to locker.OutputPath, kept for compatibility: the json format switches
+// the path to output.json in the working directory. Unlike Export, format names are matched exactly and unknown
+// ones fall back to the text format, as they always did. Prefer Export and ExportFile.
 func (locker *Locker) ExportOutput(result interface{}, dataFormat string) error {
-	var data []byte
-	var err error
-	switch dataFormat {
-	case strings.ToLower("json"):
-		data, err = json.MarshalIndent(result, "", "  ")
-		if err != nil {
-			types.CURRENT_ERR = types.ERR_FUNC
-			return fmt.Errorf("error writing data to file: %w", err)
-		}
+	exportersMutex.RLock()
+	_, ok := exporters[dataFormat]
+	exportersMutex.RUnlock()
+	if !ok {
+		dataFormat = types.FORMAT_TEXT
+	}
+
+	var buffer bytes.Buffer
+	err := locker.export(&buffer, dataFormat, result)
+	if err != nil {
+		return err
+	}
+
+	if dataFormat == types.FORMAT_JSON {
 		locker.OutputPath = filepath.Join(locker.WorkingDir, "output.json")
-	default:
-		switch resultAsserted := result.(type) {
-		case []types.Secret:
-			for _, item := range resultAsserted {
-				statement := fmt.Sprintf("%s = %s\n", item.Key, item.Value)
-				data = append(data, []byte(statement)...)
-			}
-		case types.Secret:
-			statement := fmt.Sprintf("%s = %s\n", resultAsserted.Key, resultAsserted.Value)
-			data = append(data, []byte(statement)...)
-		case []types.Environment:
-			for _, item := range resultAsserted {
-				statement := fmt.Sprintf("%s = %s\n", item.Name, item.ExternalURL)
-				data = append(data, []byte(statement)...)
-			}
-		case types.Environment:
-			statement := fmt.Sprintf("%s = %s\n", resultAsserted.Name, resultAsserted.ExternalURL)
-			data = append(data, []byte(statement)...)
-		case types.EncryptedSecResponse:
-			switch locker.currentOperation {
-			case types.OPERATION_CREATE:
-				statement := fmt.Sprintf("creation of secret item with key %s and value %s completed\n", resultAsserted.Key, resultAsserted.Value)
-				data = append(data, []byte(statement)...)
-			case types.OPERATION_UPDATE:
-				statement := fmt.Sprintf("modification of secret item %s completed, current key, value is %s and %s\n", resultAsserted.ID, resultAsserted.Key, resultAsserted.Value)
-				data = append(data, []byte(statement)...)
-			}
-		case types.EncryptedEnvResponse:
-			switch locker.currentOperation {
-			case types.OPERATION_CREATE:
-				statement := fmt.Sprintf("creation of enviroment item with name %s and url %s completed\n", resultAsserted.Name, resultAsserted.ExternalURL)
-				data = append(data, []byte(statement)...)
-			case types.OPERATION_UPDATE:
-				statement := fmt.Sprintf("modification of enviroment item %s completed, current name, url is %s and %s\n", resultAsserted.ID, resultAsserted.Name, resultAsserted.ExternalURL)
-				data = append(data, []byte(statement)...)
-			}
-		}
 	}
 
-	err = os.WriteFile(locker.OutputPath, []byte(data), 0640)
+	return writeFileAtomic(locker.OutputPath, buffer.Bytes(), 0600)
+}
+
+// Export writes result to w in the given format
+func Export(w io.Writer, dataFormat string, result interface{}) error {
+	exportFn, err := getExporter(dataFormat)
 	if err != nil {
-		types.CURRENT_ERR = types.ERR_PATH
-		return fmt.Errorf("error writing data to file: %w", err)
+		return err
 	}
-	return nil
+
+	return exportFn(w, result)
+}
+
+// ExportFile atomically writes result to path in the given format: the data is rendered first, written to a
+// temporary file in the same directory and renamed over path, so a partially written file is never left behind.
+// perm defaults to 0600.
+func ExportFile(path, dataFormat string, result interface{}, perm os.FileMode) error {
+	if perm == 0 {
+		perm = 0600
+	}
+
+	var buffer bytes.Buffer
+	err := Export(&buffer, dataFormat, result)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, buffer.Bytes(), perm)
+}
+
+func (locker *Locker) export(w io.Writer, dataFormat string, result interface{}) error {
+	// the text format reports create/update results as a sentence
+	if dataFormat == "" || strings.EqualFold(dataFormat, types.FORMAT_TEXT) {
+		if summary, ok := locker.operationSummary(result); ok {
+			_, err := io.WriteString(w, summary)
+			return err
+		}
+	}
+
+	return Export(w, dataFormat, result)
+}
+
+func (locker *Locker) operationSummary(result interface{}) (string, bool) {
+	switch resultAsserted := result.(type) {
+	case types.EncryptedSecResponse:
+		switch locker.currentOperation {
+		case types.OPERATION_CREATE:
+			return fmt.Sprintf("creation of secret item with key %s and value %s completed\n", resultAsserted.Key, resultAsserted.Value), true
+		case types.OPERATION_UPDATE:
+			return fmt.Sprintf("modification of secret item %s completed, current key, value is %s and %s\n", resultAsserted.ID, resultAsserted.Key, resultAsserted.Value), true
+		}
+	case types.EncryptedEnvResponse:
+		switch locker.currentOperation {
+		case types.OPERATION_CREATE:
+			return fmt.Sprintf("creation of enviroment item with name %s and url %s completed\n", resultAsserted.Name, resultAsserted.ExternalURL), true
+		case types.OPERATION_UPDATE:
+			return fmt.Sprintf("modification of enviroment item %s completed, current name, url is %s and %s\n", resultAsserted.ID, resultAsserted.Name, resultAsserted.ExternalURL), true
+		}
+	}
+	return "", false
 }
 
 func unmarshalAny[Struct any](bytes []byte) (*Struct, error) {
@@ -76,3 +104,91 @@ func unmarshalAny[Struct any](bytes []byte) (*Struct, error) {
 	}
 	return out, nil
 }
+
+// matchAnyPattern reports whether key matches one of the glob patterns (path.Match syntax)
+func matchAnyPattern(patterns []string, key string) (bool, error) {
+	for _, pattern := range patterns {
+		matched, err := path.Match(pattern, key)
+		if err != nil {
+			types.CURRENT_ERR = types.ERR_INPUT
+			return false, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// normalizeEnvName turns a secret key into a conventional variable name: spaces and dashes become "_", upper-cased
+func normalizeEnvName(key string) string {
+	replacer := strings.NewReplacer(" ", "_", "-", "_")
+	return strings.ToUpper(replacer.Replace(strings.TrimSpace(key)))
+}
+
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		types.CURRENT_ERR = types.ERR_PATH
+		return fmt.Errorf("error creating temporary file: %w", err)
+	}
+	tmpPath := tmpFile.Name()
+
+	// remove the temporary file on any failure, it may hold part of the data
+	committed := false
+	defer func() {
+		if !committed {
+			tmpFile.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	err = tmpFile.Chmod(perm)
+	if err != nil {
+		types.CURRENT_ERR = types.ERR_FILE
+		return fmt.Errorf("error setting file permission: %w", err)
+	}
+
+	_, err = tmpFile.Write(data)
+	if err != nil {
+		types.CURRENT_ERR = types.ERR_FILE
+		return fmt.Errorf("error writing data to file: %w", err)
+	}
+
+	err = tmpFile.Sync()
+	if err != nil {
+		types.CURRENT_ERR = types.ERR_FILE
+		return fmt.Errorf("error writing data to file: %w", err)
+	}
+
+	err = tmpFile.Close()
+	if err != nil {
+		types.CURRENT_ERR = types.ERR_FILE
+		return fmt.Errorf("error writing data to file: %w", err)
+	}
+
+	err = os.Rename(tmpPath, path)
+	if err != nil {
+		types.CURRENT_ERR = types.ERR_PATH
+		return fmt.Errorf("error writing data to file: %w", err)
+	}
+	committed = true
+
+	return nil
+}
+
+func derefString(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+// copyString returns a pointer to a copy of *value, so later in-place changes to value do not show through
+func copyString(value *string) *string {
+	if value == nil {
+		return nil
+	}
+	copied := *value
+	return &copied
+}
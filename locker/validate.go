@@ -0,0 +1,392 @@
+package locker
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lockerpm/secrets-sdk-go/types"
+)
+
+// ValidationRule checks a secret value, Check returns a description of the problem or nil
+type ValidationRule struct {
+	Name  string
+	Check func(value string) error
+}
+
+// ValidationError is a value rejected by a rule, it never contains the value itself
+type ValidationError struct {
+	Key         string `json:"key"`
+	Environment string `json:"environment,omitempty"`
+	Rule        string `json:"rule"`
+	Message     string `json:"message"`
+}
+
+func (validationErr ValidationError) Error() string {
+	if validationErr.Environment != "" {
+		return fmt.Sprintf("%s (%s): %s: %s", validationErr.Key, validationErr.Environment, validationErr.Rule, validationErr.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", validationErr.Key, validationErr.Rule, validationErr.Message)
+}
+
+// ValidationErrors is returned by CreateSecret and UpdateSecret when a value breaks one or more rules
+type ValidationErrors []ValidationError
+
+func (validationErrs ValidationErrors) Error() string {
+	messages := make([]string, 0, len(validationErrs))
+	for _, validationErr := range validationErrs {
+		messages = append(messages, validationErr.Error())
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+type validationEntry struct {
+	pattern string
+	rules   []ValidationRule
+}
+
+// AddValidationRules attaches rules to the keys matching pattern (path.Match syntax, "*" matches every key).
+// Rules run in CreateSecret and UpdateSecret before the value is encrypted.
+func (locker *Locker) AddValidationRules(pattern string, rules ...ValidationRule) error {
+	_, err := matchAnyPattern([]string{pattern}, "")
+	if err != nil {
+		return err
+	}
+
+	for _, rule := range rules {
+		if rule.Check == nil {
+			types.CURRENT_ERR = types.ERR_INPUT
+			return fmt.Errorf("validation rule %q has no check", rule.Name)
+		}
+	}
+
+	locker.validationRules = append(locker.validationRules, validationEntry{pattern: pattern, rules: rules})
+	return nil
+}
+
+// ClearValidationRules removes every registered rule
+func (locker *Locker) ClearValidationRules() {
+	locker.validationRules = nil
+}
+
+// ValidateSecret runs the rules matching key against value
+func (locker *Locker) ValidateSecret(key, value string, env *string) error {
+	var validationErrs ValidationErrors
+	for _, entry := range locker.validationRules {
+		matched, err := matchAnyPattern([]string{entry.pattern}, key)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			continue
+		}
+
+		for _, rule := range entry.rules {
+			err := rule.Check(value)
+			if err == nil {
+				continue
+			}
+
+			validationErr := ValidationError{Key: key, Rule: rule.Name, Message: err.Error()}
+			if env != nil {
+				validationErr.Environment = *env
+			}
+			validationErrs = append(validationErrs, validationErr)
+		}
+	}
+
+	if len(validationErrs) > 0 {
+		types.CURRENT_ERR = types.ERR_INPUT
+		return validationErrs
+	}
+	return nil
+}
+
+// ValidateEnvironment audits the secrets defined in env (ALL when env == nil) against the registered rules,
+// the error is only set when the secrets cannot be read
+func (locker *Locker) ValidateEnvironment(env *string) (ValidationErrors, error) {
+	if env != nil {
+		_, err := locker.GetEnvironment(*env)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	secrets, err := locker.listScopedSecrets(env)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(secrets))
+	for key := range secrets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var validationErrs ValidationErrors
+	for _, key := range keys {
+		err := locker.ValidateSecret(key, secrets[key].Value, env)
+		if err == nil {
+			continue
+		}
+
+		secretErrs, ok := err.(ValidationErrors)
+		if !ok {
+			return nil, err
+		}
+		validationErrs = append(validationErrs, secretErrs...)
+	}
+
+	return validationErrs, nil
+}
+
+// MatchRegex requires the whole value to match pattern
+func MatchRegex(pattern string) (ValidationRule, error) {
+	regex, err := regexp.Compile(`^(?:` + pattern + `)$`)
+	if err != nil {
+		types.CURRENT_ERR = types.ERR_INPUT
+		return ValidationRule{}, fmt.Errorf("invalid regex %q: %w", pattern, err)
+	}
+
+	return ValidationRule{
+		Name: "regex",
+		Check: func(value string) error {
+			if !regex.MatchString(value) {
+				return fmt.Errorf("value does not match %q", pattern)
+			}
+			return nil
+		},
+	}, nil
+}
+
+// MinLength requires at least length characters
+func MinLength(length int) ValidationRule {
+	return ValidationRule{
+		Name: "min_length",
+		Check: func(value string) error {
+			if count := utf8.RuneCountInString(value); count < length {
+				return fmt.Errorf("value has %d characters, at least %d required", count, length)
+			}
+			return nil
+		},
+	}
+}
+
+// MaxLength allows at most length characters
+func MaxLength(length int) ValidationRule {
+	return ValidationRule{
+		Name: "max_length",
+		Check: func(value string) error {
+			if count := utf8.RuneCountInString(value); count > length {
+				return fmt.Errorf("value has %d characters, at most %d allowed", count, length)
+			}
+			return nil
+		},
+	}
+}
+
+// ValidURL requires an absolute URL with a host, restricted to schemes when given
+func ValidURL(schemes ...string) ValidationRule {
+	return ValidationRule{
+		Name: "url",
+		Check: func(value string) error {
+			parsedURL, err := url.Parse(value)
+			if err != nil {
+				return fmt.Errorf("value is not a valid URL")
+			}
+			if parsedURL.Scheme == "" || parsedURL.Host == "" {
+				return fmt.Errorf("value is not an absolute URL")
+			}
+			if len(schemes) == 0 {
+				return nil
+			}
+			for _, scheme := range schemes {
+				if strings.EqualFold(parsedURL.Scheme, scheme) {
+					return nil
+				}
+			}
+			return fmt.Errorf("URL scheme %q is not one of %s", parsedURL.Scheme, strings.Join(schemes, ", "))
+		},
+	}
+}
+
+// PEMBlock requires one or more complete PEM blocks and nothing else, restricted to blockTypes
+// (e.g. "CERTIFICATE", "PRIVATE KEY") when given
+func PEMBlock(blockTypes ...string) ValidationRule {
+	return ValidationRule{
+		Name: "pem",
+		Check: func(value string) error {
+			rest := []byte(value)
+			count := 0
+			for {
+				// pem.Decode skips anything before a block, which is not allowed here
+				if !bytes.HasPrefix(bytes.TrimSpace(rest), []byte("-----BEGIN ")) {
+					break
+				}
+				var block *pem.Block
+				block, rest = pem.Decode(rest)
+				if block == nil {
+					break
+				}
+				count++
+
+				if len(blockTypes) == 0 {
+					continue
+				}
+				allowed := false
+				for _, blockType := range blockTypes {
+					if block.Type == blockType {
+						allowed = true
+						break
+					}
+				}
+				if !allowed {
+					return fmt.Errorf("unexpected PEM block %q", block.Type)
+				}
+			}
+
+			if count == 0 {
+				return fmt.Errorf("value contains no complete PEM block")
+			}
+			if strings.TrimSpace(string(rest)) != "" {
+				return fmt.Errorf("value contains data after the last complete PEM block")
+			}
+			return nil
+		},
+	}
+}
+
+// IntRange requires a base 10 integer within [min, max]
+func IntRange(min, max int64) ValidationRule {
+	return ValidationRule{
+		Name: "int_range",
+		Check: func(value string) error {
+			number, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			if err != nil {
+				return fmt.Errorf("value is not an integer")
+			}
+			if number < min || number > max {
+				return fmt.Errorf("value is not between %d and %d", min, max)
+			}
+			return nil
+		},
+	}
+}
+
+// JSONSchema is a small subset of JSON Schema: an empty schema accepts any JSON document
+type JSONSchema struct {
+	// Type is one of "object", "array", "string", "number", "integer", "boolean" and "null"
+	Type       string
+	Required   []string
+	Properties map[string]JSONSchema
+	Items      *JSONSchema
+	Enum       []interface{}
+}
+
+// MatchJSONSchema requires a JSON document matching schema
+func MatchJSONSchema(schema JSONSchema) ValidationRule {
+	return ValidationRule{
+		Name: "json_schema",
+		Check: func(value string) error {
+			var document interface{}
+			decoder := json.NewDecoder(strings.NewReader(value))
+			decoder.UseNumber()
+			err := decoder.Decode(&document)
+			if err != nil {
+				return fmt.Errorf("value is not valid JSON")
+			}
+			// only whitespace may follow the top-level value
+			if _, err := decoder.Token(); err != io.EOF {
+				return fmt.Errorf("value is not valid JSON")
+			}
+			return schema.check(document, "$")
+		},
+	}
+}
+
+func (schema JSONSchema) check(document interface{}, location string) error {
+	if schema.Type != "" && jsonType(document, schema.Type) != schema.Type {
+		return fmt.Errorf("%s must be of type %s, got %s", location, schema.Type, jsonType(document, ""))
+	}
+
+	if len(schema.Enum) > 0 {
+		found := false
+		for _, allowed := range schema.Enum {
+			if fmt.Sprint(allowed) == fmt.Sprint(document) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s is not one of the allowed values", location)
+		}
+	}
+
+	switch documentAsserted := document.(type) {
+	case map[string]interface{}:
+		for _, field := range schema.Required {
+			if _, ok := documentAsserted[field]; !ok {
+				return fmt.Errorf("%s.%s is required", location, field)
+			}
+		}
+
+		fields := make([]string, 0, len(schema.Properties))
+		for field := range schema.Properties {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			fieldValue, ok := documentAsserted[field]
+			if !ok {
+				continue
+			}
+			err := schema.Properties[field].check(fieldValue, location+"."+field)
+			if err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if schema.Items == nil {
+			return nil
+		}
+		for i, item := range documentAsserted {
+			err := schema.Items.check(item, fmt.Sprintf("%s[%d]", location, i))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// jsonType names the type of a decoded document, numbers without fraction are integers when expected is "integer"
+func jsonType(document interface{}, expected string) string {
+	switch documentAsserted := document.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if expected == "integer" {
+			if _, err := documentAsserted.Int64(); err == nil {
+				return "integer"
+			}
+		}
+		return "number"
+	default:
+		return "null"
+	}
+}
@@ -1,6 +1,8 @@
 package test
 
 import (
+	"context"
+	"errors"
 	"log"
 	"os"
 	"strings"
@@ -469,3 +471,64 @@ func TestUpdateSecretEnvToALL(t *testing.T) {
 // // Current state
 // // Secret: (INIT_SEC_KEY, INIT_SEC_VAL, ALL), (CREATE_SEC_KEY, CREATE_SEC_VAL, ALL), (UPDATE_SEC_KEY, CREATE_SEC_VAL, ALL), (UPDATE_SEC_KEY, UPDATE_SEC_VAL, UPDATE_ENV_NAME)
 // // Env: (INIT_ENV_NAME, INIT_ENV_URL), (UPDATE_ENV_NAME, UPDATE_ENV_URL)
+
+// LOAD TEST
+func TestLoadStruct(t *testing.T) {
+	var cfg struct {
+		Init    string   `locker:"init secret key,required"`
+		Timeout string   `locker:"not existed,default=5s"`
+		Hosts   []string `locker:"not existed either,default=a,b"`
+	}
+	err := lockerClient.Load(context.Background(), &cfg)
+	if err != nil {
+		t.Fatalf("load broke, error: %v", err)
+	}
+	if cfg.Init != INIT_SEC_VAL {
+		t.Fatalf("load broke, expecting value \"%s\", getting \"%s\" instead", INIT_SEC_VAL, cfg.Init)
+	}
+	if cfg.Timeout != "5s" || len(cfg.Hosts) != 2 {
+		t.Fatalf("load broke, defaults not applied, getting %+v", cfg)
+	}
+}
+
+func TestLoadStructMissing(t *testing.T) {
+	var cfg struct {
+		First  string `locker:"missing key 1,required"`
+		Second string `locker:"missing key 2,env=init env name,required"`
+	}
+	err := lockerClient.Load(context.Background(), &cfg)
+	var loadErr *locker.LoadError
+	if !errors.As(err, &loadErr) {
+		t.Fatalf("expecting load error, getting: %v", err)
+	}
+	if len(loadErr.Missing) != 2 {
+		t.Fatalf("expecting 2 missing keys, getting %v", loadErr.Missing)
+	}
+}
+
+// DOTENV TEST
+func TestImportDotenvDryRun(t *testing.T) {
+	content := "# comment\nexport NEW_DOTENV_KEY=\"multi\\nline\"\n"
+	plan, err := lockerClient.ImportDotenv(strings.NewReader(content), nil, &locker.ImportOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("import dotenv broke, error: %v", err)
+	}
+	if len(plan.Changes) != 1 || plan.Changes[0].Action != types.ACTION_CREATE || plan.Changes[0].Applied {
+		t.Fatalf("import dotenv broke, expecting 1 unapplied creation, getting %+v", plan.Changes)
+	}
+}
+
+// COMPARE TEST
+func TestCompareEnvironments(t *testing.T) {
+	env := INIT_ENV_NAME
+	diff, err := lockerClient.CompareEnvironments(nil, &env)
+	if err != nil {
+		t.Fatalf("compare environments broke, error: %v", err)
+	}
+	for _, key := range diff.OnlyInA {
+		if key == INIT_SEC_KEY {
+			return
+		}
+	}
+	t.Fatalf("compare environments broke, expecting \"%s\" only in ALL, getting %+v", INIT_SEC_KEY, diff)
+}
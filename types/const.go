@@ -16,6 +16,7 @@ const ERR_HTTP = "http_error"
 const ERR_FILE = "file_error"
 const ERR_PATH = "path_error"
 const ERR_DB = "database_error"
+const ERR_CONFLICT = "conflict_error"
 
 const SERVER_ERR_MSG_DUP = "hash already exists"
 
@@ -32,3 +33,50 @@ const DB_REVISION_NUMBER = 1
 
 const OPERATION_CREATE = "CREATE"
 const OPERATION_UPDATE = "UPDATE"
+const OPERATION_DELETE = "DELETE"
+
+const PRECEDENCE_SECRET = "secret"
+const PRECEDENCE_OS = "os"
+
+const ACTION_CREATE = "create"
+const ACTION_UPDATE = "update"
+const ACTION_UNCHANGED = "unchanged"
+const ACTION_SKIP = "skip"
+const ACTION_DELETE = "delete"
+
+const CONFLICT_SKIP = "skip"
+const CONFLICT_OVERWRITE = "overwrite"
+const CONFLICT_FAIL = "fail"
+
+const FORMAT_JSON = "json"
+const FORMAT_TEXT = "text"
+const FORMAT_DOTENV = "dotenv"
+const FORMAT_SHELL = "shell"
+const FORMAT_YAML = "yaml"
+const FORMAT_DOCKER = "docker"
+const FORMAT_SYSTEMD = "systemd"
+const FORMAT_PROPERTIES = "properties"
+const FORMAT_KUBERNETES = "kubernetes"
+
+const SCOPE_ANY = "any"
+const SCOPE_ALL = "all"
+const SCOPE_ENV = "env"
+
+const SORT_KEY = "key"
+const SORT_CREATION_DATE = "creation_date"
+const SORT_REVISION_DATE = "revision_date"
+
+const USAGE_NEVER_USED = "never_used"
+const USAGE_UNUSED = "unused"
+const USAGE_ROTATION_DUE = "rotation_due"
+
+const ENV_ALL = "ALL"
+
+const GEN_FORMAT_CHARS = "chars"
+const GEN_FORMAT_HEX = "hex"
+const GEN_FORMAT_BASE64URL = "base64url"
+
+const REDACTED = "[REDACTED]"
+
+const AUDIT_RESULT_SUCCESS = "success"
+const AUDIT_RESULT_FAILURE = "failure"
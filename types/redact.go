@@ -0,0 +1,307 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+)
+
+// JSONRevealer is implemented by types whose JSON encoding is redacted, RevealedJSON returns a value
+// encoding the plaintext instead
+type JSONRevealer interface {
+	RevealedJSON() interface{}
+}
+
+// aliases without methods, used to format and encode the plaintext
+type secretAlias Secret
+type encryptedSecResponseAlias EncryptedSecResponse
+
+func redactString(value string) string {
+	if value == "" {
+		return ""
+	}
+	return REDACTED
+}
+
+// Reveal returns the plaintext value
+func (secret Secret) Reveal() string {
+	return secret.Value
+}
+
+// RevealedJSON returns a value encoding secret with its plaintext value
+func (secret Secret) RevealedJSON() interface{} {
+	return secretAlias(secret)
+}
+
+func (secret Secret) redacted() secretAlias {
+	secret.Value = redactString(secret.Value)
+	return secretAlias(secret)
+}
+
+// Format prints secret like a plain struct with its value redacted, for every verb
+func (secret Secret) Format(state fmt.State, verb rune) {
+	if verb == 'v' && state.Flag('#') {
+		_, _ = io.WriteString(state, secret.GoString())
+		return
+	}
+	fmt.Fprintf(state, fmt.FormatString(state, verb), secret.redacted())
+}
+
+func (secret Secret) GoString() string {
+	return strings.Replace(fmt.Sprintf("%#v", secret.redacted()), "types.secretAlias", "types.Secret", 1)
+}
+
+func (secret Secret) LogValue() slog.Value {
+	return slog.AnyValue(secret.redacted())
+}
+
+// MarshalJSON encodes secret with its value redacted, see MarshalRevealed
+func (secret Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(secret.redacted())
+}
+
+// Reveal returns the plaintext value
+func (response EncryptedSecResponse) Reveal() string {
+	return response.Value
+}
+
+// RevealedJSON returns a value encoding response with its plaintext value
+func (response EncryptedSecResponse) RevealedJSON() interface{} {
+	return encryptedSecResponseAlias(response)
+}
+
+func (response EncryptedSecResponse) redacted() encryptedSecResponseAlias {
+	response.Value = redactString(response.Value)
+	response.Data.Value = redactString(response.Data.Value)
+	return encryptedSecResponseAlias(response)
+}
+
+// Format prints response like a plain struct with its value redacted, for every verb
+func (response EncryptedSecResponse) Format(state fmt.State, verb rune) {
+	if verb == 'v' && state.Flag('#') {
+		_, _ = io.WriteString(state, response.GoString())
+		return
+	}
+	fmt.Fprintf(state, fmt.FormatString(state, verb), response.redacted())
+}
+
+func (response EncryptedSecResponse) GoString() string {
+	return strings.Replace(fmt.Sprintf("%#v", response.redacted()), "types.encryptedSecResponseAlias", "types.EncryptedSecResponse", 1)
+}
+
+func (response EncryptedSecResponse) LogValue() slog.Value {
+	return slog.AnyValue(response.redacted())
+}
+
+// MarshalJSON encodes response with its value redacted, see MarshalRevealed
+func (response EncryptedSecResponse) MarshalJSON() ([]byte, error) {
+	return json.Marshal(response.redacted())
+}
+
+// MarshalRevealed is json.Marshal revealing the plaintext of JSONRevealer values, including those held in
+// pointers, slices, arrays, maps and the exported fields of other structs. A struct with embedded fields cannot
+// be rebuilt that way, it fails to encode when it may hold such values.
+func MarshalRevealed(v interface{}) ([]byte, error) {
+	return json.Marshal(revealJSON(reflect.ValueOf(v)))
+}
+
+// RevealingEncoder is a json.Encoder revealing the plaintext like MarshalRevealed, meant for exports
+type RevealingEncoder struct {
+	encoder *json.Encoder
+}
+
+func NewRevealingEncoder(w io.Writer) *RevealingEncoder {
+	return &RevealingEncoder{encoder: json.NewEncoder(w)}
+}
+
+func (encoder *RevealingEncoder) SetIndent(prefix, indent string) {
+	encoder.encoder.SetIndent(prefix, indent)
+}
+
+func (encoder *RevealingEncoder) SetEscapeHTML(on bool) {
+	encoder.encoder.SetEscapeHTML(on)
+}
+
+func (encoder *RevealingEncoder) Encode(v interface{}) error {
+	return encoder.encoder.Encode(revealJSON(reflect.ValueOf(v)))
+}
+
+// MarshalIndentRevealed is MarshalRevealed with json.MarshalIndent formatting
+func MarshalIndentRevealed(v interface{}, prefix, indent string) ([]byte, error) {
+	var buffer bytes.Buffer
+	encoder := NewRevealingEncoder(&buffer)
+	encoder.SetIndent(prefix, indent)
+	err := encoder.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buffer.Bytes(), []byte("\n")), nil
+}
+
+var jsonRevealerType = reflect.TypeOf((*JSONRevealer)(nil)).Elem()
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+func revealJSON(value reflect.Value) interface{} {
+	if !value.IsValid() {
+		return nil
+	}
+
+	if value.Type().Implements(jsonRevealerType) {
+		if value.Kind() == reflect.Pointer && value.IsNil() {
+			return nil
+		}
+		return value.Interface().(JSONRevealer).RevealedJSON()
+	}
+
+	// other custom encodings are kept
+	if value.Type().Implements(jsonMarshalerType) {
+		return value.Interface()
+	}
+
+	switch value.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if value.IsNil() {
+			return nil
+		}
+		return revealJSON(value.Elem())
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice && value.IsNil() {
+			return nil
+		}
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			// []byte keeps its base64 encoding
+			return value.Interface()
+		}
+		items := make([]interface{}, value.Len())
+		for i := range items {
+			items[i] = revealJSON(value.Index(i))
+		}
+		return items
+	case reflect.Map:
+		if value.IsNil() || value.Type().Key().Kind() != reflect.String {
+			return value.Interface()
+		}
+		items := make(map[string]interface{}, value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			items[iter.Key().String()] = revealJSON(iter.Value())
+		}
+		return items
+	case reflect.Struct:
+		return revealStruct(value)
+	default:
+		return value.Interface()
+	}
+}
+
+// revealingError fails the encoding of a value revealJSON cannot handle
+type revealingError struct {
+	err error
+}
+
+func (revealing revealingError) MarshalJSON() ([]byte, error) {
+	return nil, revealing.err
+}
+
+// revealStruct encodes value like a plain struct of the same fields and tags, with the fields that may hold
+// a JSONRevealer revealed
+func revealStruct(value reflect.Value) interface{} {
+	structType := value.Type()
+	if !mayHoldRevealer(structType, map[reflect.Type]bool{}) {
+		return value.Interface()
+	}
+
+	var fields []reflect.StructField
+	var values []interface{}
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		// reflect.StructOf cannot rebuild embedded fields with their methods
+		if field.Anonymous {
+			return revealingError{err: fmt.Errorf("cannot reveal %s: embedded field %s", structType, field.Name)}
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		fieldValue := value.Field(i)
+		if !mayHoldRevealer(field.Type, map[reflect.Type]bool{}) {
+			fields = append(fields, reflect.StructField{Name: field.Name, Type: field.Type, Tag: field.Tag})
+			values = append(values, fieldValue.Interface())
+			continue
+		}
+
+		var revealed interface{}
+		if !isEmptyJSONValue(fieldValue) || !hasOmitEmpty(field.Tag) {
+			revealed = revealJSON(fieldValue)
+		}
+		fields = append(fields, reflect.StructField{Name: field.Name, Type: interfaceType, Tag: field.Tag})
+		values = append(values, revealed)
+	}
+
+	revealed := reflect.New(reflect.StructOf(fields)).Elem()
+	for i, fieldValue := range values {
+		if fieldValue != nil {
+			revealed.Field(i).Set(reflect.ValueOf(fieldValue))
+		}
+	}
+	return revealed.Interface()
+}
+
+var interfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+
+// mayHoldRevealer reports whether values of valueType may contain a JSONRevealer encoded through revealJSON
+func mayHoldRevealer(valueType reflect.Type, visited map[reflect.Type]bool) bool {
+	if visited[valueType] {
+		return false
+	}
+	visited[valueType] = true
+
+	if valueType.Implements(jsonRevealerType) {
+		return true
+	}
+	if valueType.Implements(jsonMarshalerType) {
+		return false
+	}
+
+	switch valueType.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Pointer, reflect.Slice, reflect.Array:
+		return mayHoldRevealer(valueType.Elem(), visited)
+	case reflect.Map:
+		return valueType.Key().Kind() == reflect.String && mayHoldRevealer(valueType.Elem(), visited)
+	case reflect.Struct:
+		for i := 0; i < valueType.NumField(); i++ {
+			field := valueType.Field(i)
+			if (field.IsExported() || field.Anonymous) && mayHoldRevealer(field.Type, visited) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasOmitEmpty(tag reflect.StructTag) bool {
+	_, options, _ := strings.Cut(tag.Get("json"), ",")
+	for _, option := range strings.Split(options, ",") {
+		if option == "omitempty" {
+			return true
+		}
+	}
+	return false
+}
+
+// isEmptyJSONValue matches the values omitted by encoding/json with omitempty
+func isEmptyJSONValue(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return value.Len() == 0
+	case reflect.Interface, reflect.Pointer:
+		return value.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,244 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testPlaintext = "plain-s3cr3t-value"
+
+func testSecret() Secret {
+	env := "production"
+	return Secret{ID: "sec-1", Key: "DB_PASSWORD", Value: testPlaintext, Description: "database", EnvironmentName: &env}
+}
+
+func testEncryptedSecResponse() EncryptedSecResponse {
+	response := EncryptedSecResponse{ID: "sec-1", Key: "DB_PASSWORD", Value: testPlaintext}
+	response.Data.Key = "DB_PASSWORD"
+	response.Data.Value = testPlaintext
+	return response
+}
+
+func TestSecretTypesRedacted(t *testing.T) {
+	values := map[string]interface{}{
+		"Secret":               testSecret(),
+		"*Secret":              func() *Secret { secret := testSecret(); return &secret }(),
+		"EncryptedSecResponse": testEncryptedSecResponse(),
+		"[]Secret":             []Secret{testSecret()},
+		"map[string]Secret":    map[string]Secret{"DB_PASSWORD": testSecret()},
+	}
+
+	for name, value := range values {
+		for _, verb := range []string{"%v", "%+v", "%#v", "%s", "%q"} {
+			output := fmt.Sprintf(verb, value)
+			if strings.Contains(output, testPlaintext) {
+				t.Fatalf("%s broke, %s leaks the value: %s", name, verb, output)
+			}
+			if !strings.Contains(output, "DB_PASSWORD") {
+				t.Fatalf("%s broke, %s must keep the other fields: %s", name, verb, output)
+			}
+		}
+
+		data, err := json.Marshal(value)
+		if err != nil {
+			t.Fatalf("%s json encoding broke, error: %v", name, err)
+		}
+		if strings.Contains(string(data), testPlaintext) || !strings.Contains(string(data), REDACTED) {
+			t.Fatalf("%s broke, json.Marshal leaks the value: %s", name, data)
+		}
+
+		var output bytes.Buffer
+		slog.New(slog.NewJSONHandler(&output, nil)).Info("message", "secret", value)
+		if strings.Contains(output.String(), testPlaintext) {
+			t.Fatalf("%s broke, slog leaks the value: %s", name, output.String())
+		}
+	}
+}
+
+func TestSecretGoString(t *testing.T) {
+	output := fmt.Sprintf("%#v", testSecret())
+	if !strings.HasPrefix(output, "types.Secret{") || !strings.Contains(output, `Value:"`+REDACTED+`"`) {
+		t.Fatalf("secret GoString broke, getting %s", output)
+	}
+
+	output = fmt.Sprintf("%#v", testEncryptedSecResponse())
+	if !strings.HasPrefix(output, "types.EncryptedSecResponse{") {
+		t.Fatalf("response GoString broke, getting %s", output)
+	}
+}
+
+func TestSecretEmptyValueNotRedacted(t *testing.T) {
+	data, err := json.Marshal(Secret{Key: "EMPTY"})
+	if err != nil {
+		t.Fatalf("json encoding broke, error: %v", err)
+	}
+	if strings.Contains(string(data), REDACTED) {
+		t.Fatalf("json encoding broke, an empty value has nothing to redact: %s", data)
+	}
+}
+
+func TestMarshalRevealed(t *testing.T) {
+	secret := testSecret()
+	values := map[string]interface{}{
+		"Secret":               secret,
+		"*Secret":              &secret,
+		"EncryptedSecResponse": testEncryptedSecResponse(),
+		"[]Secret":             []Secret{secret},
+		"[1]Secret":            [1]Secret{secret},
+		"map[string]Secret":    map[string]Secret{"DB_PASSWORD": secret},
+		"map[string]*Secret":   map[string]*Secret{"DB_PASSWORD": &secret},
+		"[]interface{}":        []interface{}{secret},
+	}
+
+	for name, value := range values {
+		data, err := MarshalRevealed(value)
+		if err != nil {
+			t.Fatalf("%s MarshalRevealed broke, error: %v", name, err)
+		}
+		if !strings.Contains(string(data), testPlaintext) || strings.Contains(string(data), REDACTED) {
+			t.Fatalf("%s MarshalRevealed broke, getting %s", name, data)
+		}
+
+		indented, err := MarshalIndentRevealed(value, "", "  ")
+		if err != nil {
+			t.Fatalf("%s MarshalIndentRevealed broke, error: %v", name, err)
+		}
+		var compacted bytes.Buffer
+		err = json.Compact(&compacted, indented)
+		if err != nil || compacted.String() != string(data) {
+			t.Fatalf("%s MarshalIndentRevealed broke, getting %s", name, indented)
+		}
+	}
+
+	// the revealed encoding matches the field names of the plain struct
+	var decoded map[string]interface{}
+	data, _ := MarshalRevealed(secret)
+	_ = json.Unmarshal(data, &decoded)
+	if decoded["value"] != testPlaintext || decoded["key"] != "DB_PASSWORD" || decoded["environment_name"] != "production" {
+		t.Fatalf("MarshalRevealed broke, getting %s", data)
+	}
+
+	data, err := MarshalRevealed(testEncryptedSecResponse())
+	if err != nil || strings.Count(string(data), testPlaintext) != 2 {
+		t.Fatalf("MarshalRevealed broke, the nested data value must be revealed too, getting %s", data)
+	}
+}
+
+type testNode struct {
+	Name string    `json:"name"`
+	Next *testNode `json:"next,omitempty"`
+}
+
+func TestMarshalRevealedNested(t *testing.T) {
+	env := "production"
+	type wrapper struct {
+		Secret    Secret            `json:"secret"`
+		Optional  *Secret           `json:"optional,omitempty"`
+		Items     []Secret          `json:"items,omitempty"`
+		Any       interface{}       `json:"any"`
+		Env       *string           `json:"env"`
+		CreatedAt time.Time         `json:"created_at"`
+		Labels    map[string]string `json:"labels,omitempty"`
+		Renamed   string            `json:"renamed_field"`
+		Skipped   string            `json:"-"`
+		private   string
+	}
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	value := wrapper{
+		Secret:    testSecret(),
+		Items:     []Secret{testSecret()},
+		Any:       testSecret(),
+		Env:       &env,
+		CreatedAt: created,
+		Renamed:   "renamed",
+		Skipped:   "skipped",
+		private:   "private",
+	}
+
+	// values held by other structs are revealed, the other fields keep their plain encoding
+	data, err := MarshalRevealed([]wrapper{value})
+	if err != nil {
+		t.Fatalf("MarshalRevealed broke, error: %v", err)
+	}
+	if strings.Count(string(data), testPlaintext) != 3 || strings.Contains(string(data), REDACTED) {
+		t.Fatalf("MarshalRevealed broke, nested values must be revealed, getting %s", data)
+	}
+
+	plain, err := json.Marshal(value)
+	if err != nil {
+		t.Fatalf("json encoding broke, error: %v", err)
+	}
+	want := strings.ReplaceAll(string(plain), REDACTED, testPlaintext)
+	if string(data) != "["+want+"]" {
+		t.Fatalf("MarshalRevealed broke, expecting the plain encoding with the values revealed\n%s\ngetting\n%s", want, data)
+	}
+
+	indented, err := MarshalIndentRevealed(struct {
+		Secret Secret `json:"secret"`
+	}{Secret: testSecret()}, "", "  ")
+	if err != nil || !strings.Contains(string(indented), `"value": "`+testPlaintext+`"`) {
+		t.Fatalf("MarshalIndentRevealed broke for a nested value, getting %s (%v)", indented, err)
+	}
+
+	// recursive types without any revealer are encoded as they are
+	data, err = MarshalRevealed(testNode{Name: "a", Next: &testNode{Name: "b"}})
+	if err != nil || string(data) != `{"name":"a","next":{"name":"b"}}` {
+		t.Fatalf("MarshalRevealed broke for a recursive type, getting %s (%v)", data, err)
+	}
+}
+
+func TestMarshalRevealedEmbedded(t *testing.T) {
+	type metadata struct {
+		Owner string `json:"owner"`
+	}
+
+	// embedded fields cannot be rebuilt, the encoding fails instead of leaking or losing data
+	_, err := MarshalRevealed(struct {
+		metadata
+		Secret Secret `json:"secret"`
+	}{Secret: testSecret()})
+	if err == nil || !strings.Contains(err.Error(), "embedded field metadata") {
+		t.Fatalf("MarshalRevealed broke, expecting an error for an embedded field, getting %v", err)
+	}
+
+	// without any revealer the struct is encoded as it is
+	data, err := MarshalRevealed(struct {
+		metadata
+		Name string `json:"name"`
+	}{metadata: metadata{Owner: "ops"}, Name: "n"})
+	if err != nil || string(data) != `{"owner":"ops","name":"n"}` {
+		t.Fatalf("MarshalRevealed broke for an embedded field, getting %s (%v)", data, err)
+	}
+
+	data, err = MarshalRevealed(nil)
+	if err != nil || string(data) != "null" {
+		t.Fatalf("MarshalRevealed broke for nil, getting %s (%v)", data, err)
+	}
+
+	var nilSecret *Secret
+	data, err = MarshalRevealed(nilSecret)
+	if err != nil || string(data) != "null" {
+		t.Fatalf("MarshalRevealed broke for a nil pointer, getting %s (%v)", data, err)
+	}
+}
+
+func TestRevealingEncoder(t *testing.T) {
+	var output bytes.Buffer
+	encoder := NewRevealingEncoder(&output)
+	encoder.SetEscapeHTML(false)
+
+	secret := testSecret()
+	secret.Value = "<&>"
+	err := encoder.Encode([]Secret{secret})
+	if err != nil {
+		t.Fatalf("revealing encoder broke, error: %v", err)
+	}
+	if !strings.Contains(output.String(), `"value":"<&>"`) || !strings.HasSuffix(output.String(), "\n") {
+		t.Fatalf("revealing encoder broke, getting %s", output.String())
+	}
+}